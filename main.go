@@ -2,74 +2,315 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
+	"fmt"
+	"net/http"
 	"os"
+	"regexp"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 
 	"github.com/numaproj-labs/numalogic-config-aggregator/pkg/aggregator"
+	"github.com/numaproj-labs/numalogic-config-aggregator/pkg/cmd"
+	"github.com/numaproj-labs/numalogic-config-aggregator/pkg/config"
+	"github.com/numaproj-labs/numalogic-config-aggregator/pkg/health"
 	"github.com/numaproj-labs/numalogic-config-aggregator/pkg/leaderelection"
 	"github.com/numaproj-labs/numalogic-config-aggregator/pkg/logging"
+	"github.com/numaproj-labs/numalogic-config-aggregator/pkg/metrics"
+	"github.com/numaproj-labs/numalogic-config-aggregator/pkg/webhook"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "validate":
+			os.Exit(cmd.Validate(os.Args[2:], os.Stdout, os.Stderr))
+		case "render":
+			os.Exit(cmd.Render(os.Args[2:], os.Stdout, os.Stderr))
+		case "rollback":
+			restConfig, err := getClientConfig()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "failed to retrieve kubernetes config, %v\n", err)
+				os.Exit(2)
+			}
+			client, err := kubernetes.NewForConfig(restConfig)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "failed to create kubernetes client, %v\n", err)
+				os.Exit(2)
+			}
+			os.Exit(cmd.Rollback(context.Background(), client, os.Args[2:], os.Stdout, os.Stderr))
+		}
+	}
+
 	logger := logging.NewLogger()
 
 	var (
-		configMapName  string
-		configMapKey   string
-		appConfigLabel string
-		interval       time.Duration
+		configMapName     string
+		configMapKey      string
+		appConfigLabel    string
+		interval          time.Duration
+		watch             bool
+		debounce          time.Duration
+		mergeNamespace    bool
+		statusAnnotations bool
+		configMapSource   bool
+		crdSource         bool
+		configFile        string
+		metricsAddr       string
+		healthAddr        string
+		webhookAddr       string
+		webhookCertDir    string
+		webhookMode       string
+		namespaceSelector string
+		includeNamespaces string
+		excludeNamespaces string
+		namespaced        bool
+		maxConfigMapSize  int
+		revisionLimit     int
+		sourceDirs        string
+		local             bool
+		dryRun            bool
+		outputFile        string
+		schemaFileDir     string
+		gitRepo           string
+		gitRef            string
+		gitPathPattern    string
+		staleGracePeriod  time.Duration
+		maxRemovedPercent int
+		maxRemovedConfigs int
 	)
 
+	flag.StringVar(&configFile, "config-file", "", "Path of the config file defining multiple aggregations, the single aggregation flags are ignored if it's set")
 	flag.StringVar(&configMapName, "configmap-name", "", "Aggregated ConfigMap name")
 	flag.StringVar(&configMapKey, "configmap-key", "", "Key of the aggregated ConfigMap name")
 	flag.StringVar(&appConfigLabel, "app-config-label", "", "Label of the ConfigMap in the application namespaces")
-	flag.DurationVar(&interval, "interval", time.Second*180, "Interval of each run")
+	flag.DurationVar(&interval, "interval", time.Second*180, "Interval of each run, it works as a resync interval when watch is enabled")
+	flag.BoolVar(&watch, "watch", true, "Watch the application ConfigMaps and aggregate on changes")
+	flag.DurationVar(&debounce, "debounce", time.Second*5, "Time to wait for more changes before an event triggered aggregation")
+	flag.BoolVar(&mergeNamespace, "merge-namespace", false, "Merge the configs from multiple ConfigMaps in one namespace into one config per service")
+	flag.BoolVar(&statusAnnotations, "status-annotations", true, "Annotate the application ConfigMaps with the aggregation result")
+	flag.BoolVar(&configMapSource, "configmap-source", true, "Read the application configs from the labeled ConfigMaps")
+	flag.BoolVar(&crdSource, "crd-source", false, "Read the application configs from the NumalogicAppConfig resources")
+	flag.StringVar(&namespaceSelector, "namespace-selector", "", "Label selector of the namespaces to read the application configs from")
+	flag.StringVar(&includeNamespaces, "include-namespaces", "", "Comma separated namespaces to read the application configs from, all namespaces if it's empty")
+	flag.StringVar(&excludeNamespaces, "exclude-namespaces", "", "Regex of the namespaces not to read the application configs from")
+	flag.BoolVar(&namespaced, "namespaced", false, "List and watch the application configs in each of the --include-namespaces instead of cluster wide, so only namespaced permissions are required")
+	flag.IntVar(&maxConfigMapSize, "max-configmap-size", aggregator.DefaultMaxConfigMapSize, "Max size in bytes of the aggregated config in one ConfigMap, it's split into multiple ConfigMaps if it's larger")
+	flag.IntVar(&revisionLimit, "revision-history-limit", aggregator.DefaultRevisionHistoryLimit, "Number of the revisions of the aggregated config to keep, 0 disables the revisions")
+	flag.DurationVar(&staleGracePeriod, "stale-config-grace-period", aggregator.DefaultStaleConfigGracePeriod, "How long to keep the last valid version of an application config after it becomes invalid, 0 disables it")
+	flag.IntVar(&maxRemovedPercent, "max-removed-percent", aggregator.DefaultMaxRemovedPercent, "Max percentage of the published application configs removed in one run, the aggregated config is not published if more would be removed, 0 means no limit")
+	flag.IntVar(&maxRemovedConfigs, "max-removed-configs", 0, "Max number of the published application configs removed in one run, the aggregated config is not published if more would be removed, 0 means no limit")
+	flag.StringVar(&schemaFileDir, "schema-file-dir", "", "Dir of the schema.json file for validation, defaults to /etc/config/config-aggregator")
+	flag.StringVar(&sourceDirs, "source-dirs", "", "Comma separated local directories to read the application configs from, each sub-directory is a namespace")
+	flag.StringVar(&gitRepo, "git-repo", "", "Path of a local git checkout or bare repository to read the application configs from")
+	flag.StringVar(&gitRef, "git-ref", "HEAD", "Ref of the --git-repo to read, e.g. a branch, a tag or a commit")
+	flag.StringVar(&gitPathPattern, "git-path-pattern", aggregator.DefaultGitPathPattern, "Pattern of the application config files in the --git-repo, the name of the directory of a file is its namespace")
+	flag.BoolVar(&local, "local", false, "Run without a cluster for the local development, the application configs are only read from the --source-dirs and the --git-repo, and the aggregated config is only written to the sinks, e.g. --output-file")
+	flag.BoolVar(&dryRun, "dry-run", false, "Only compute and log the difference between the aggregated ConfigMap and the new aggregated config in each run, nothing is written, and no leader election is required")
+	flag.StringVar(&outputFile, "output-file", "", "Path of a local file to write the aggregated config to, besides the aggregated ConfigMap")
+	flag.StringVar(&metricsAddr, "metrics-addr", ":9090", "Address to serve the metrics on, set it to empty to disable")
+	flag.StringVar(&healthAddr, "health-addr", ":8081", "Address to serve the health probes /healthz and /readyz on, set it to empty to disable")
+	flag.StringVar(&webhookAddr, "webhook-addr", "", "Address to serve the validating admission webhook of the application ConfigMaps on, it's disabled if it's empty")
+	flag.StringVar(&webhookCertDir, "webhook-cert-dir", "/etc/webhook/certs", "Directory of the tls.crt and tls.key of the webhook")
+	flag.StringVar(&webhookMode, "webhook-mode", string(webhook.ModeDeny), "What the webhook does with an invalid application ConfigMap, \"deny\" or \"warn\"")
 	flag.Parse()
 
-	if configMapName == "" {
-		logger.Fatal("The name of the centralized ConfigMap is missing.")
+	var aggregations []config.Aggregation
+	if configFile != "" {
+		c, err := config.Load(configFile)
+		if err != nil {
+			logger.Fatalw("Failed to load the config file", zap.String("file", configFile), zap.Error(err))
+		}
+		aggregations = c.Aggregations
+	} else {
+		if configMapName == "" {
+			logger.Fatal("The name of the centralized ConfigMap is missing.")
+		}
+		aggregations = []config.Aggregation{{
+			Name:              configMapName,
+			ConfigMapName:     configMapName,
+			ConfigMapKey:      configMapKey,
+			AppConfigLabel:    appConfigLabel,
+			SchemaFileDir:     schemaFileDir,
+			Interval:          &metav1.Duration{Duration: interval},
+			MergeNamespace:    mergeNamespace,
+			NamespaceSelector: namespaceSelector,
+			ExcludeNamespaces: excludeNamespaces,
+			Namespaced:        namespaced,
+			MaxConfigMapSize:  maxConfigMapSize,
+		}}
+		aggregations[0].RevisionHistoryLimit = &revisionLimit
+		aggregations[0].StaleConfigGracePeriod = &metav1.Duration{Duration: staleGracePeriod}
+		aggregations[0].MaxRemovedPercent = &maxRemovedPercent
+		aggregations[0].MaxRemovedConfigs = &maxRemovedConfigs
+		if includeNamespaces != "" {
+			aggregations[0].IncludeNamespaces = strings.Split(includeNamespaces, ",")
+		}
+		if sourceDirs != "" {
+			aggregations[0].SourceDirs = strings.Split(sourceDirs, ",")
+		}
+		if gitRepo != "" {
+			aggregations[0].GitSources = []config.GitSource{{Repo: gitRepo, Ref: gitRef, PathPattern: gitPathPattern}}
+		}
+		if outputFile != "" {
+			aggregations[0].Sinks = []config.Sink{{Type: config.SinkTypeFile, Path: outputFile}}
+		}
+		if err := (&config.Config{Aggregations: aggregations}).Validate(); err != nil {
+			logger.Fatalw("Invalid flags", zap.Error(err))
+		}
 	}
 
-	namespace, existing := os.LookupEnv("NAMESPACE")
-	if !existing {
-		logger.Fatal("Required environment variable \"NAMESPACE\" is missing")
+	if local {
+		if err := (&config.Config{Aggregations: aggregations}).ValidateLocal(); err != nil {
+			logger.Fatalw("Invalid aggregations for the local mode", zap.Error(err))
+		}
 	}
 
-	hostname, existing := os.LookupEnv("POD_NAME")
-	if !existing {
-		logger.Fatal("Required environment variable \"POD_NAME\" is missing")
-	}
+	var (
+		namespace, hostname string
+		client              kubernetes.Interface
+		restConfig          *rest.Config
+		err                 error
+	)
+	opts := []aggregator.Option{aggregator.WithWatch(watch), aggregator.WithDebounce(debounce), aggregator.WithStatusAnnotations(statusAnnotations), aggregator.WithConfigMapSource(configMapSource && !local), aggregator.WithDryRun(dryRun)}
+	if !local {
+		var existing bool
+		namespace, existing = os.LookupEnv("NAMESPACE")
+		if !existing {
+			logger.Fatal("Required environment variable \"NAMESPACE\" is missing")
+		}
 
-	config, err := getClientConfig()
-	if err != nil {
-		logger.Fatalw("Failed to retrieve kubernetes config", zap.Error(err))
-	}
+		if err := (&config.Config{Aggregations: aggregations}).ValidateConfigMaps(namespace); err != nil {
+			logger.Fatalw("Invalid aggregations", zap.Error(err))
+		}
 
-	client, err := kubernetes.NewForConfig(config)
-	if err != nil {
-		logger.Fatalw("Failed to create kubernetes client", zap.Error(err))
-	}
+		hostname, existing = os.LookupEnv("POD_NAME")
+		if !existing {
+			logger.Fatal("Required environment variable \"POD_NAME\" is missing")
+		}
+
+		restConfig, err = getClientConfig()
+		if err != nil {
+			logger.Fatalw("Failed to retrieve kubernetes config", zap.Error(err))
+		}
+
+		client, err = kubernetes.NewForConfig(restConfig)
+		if err != nil {
+			logger.Fatalw("Failed to create kubernetes client", zap.Error(err))
+		}
+
+		if !configMapSource && !crdSource {
+			for _, agg := range aggregations {
+				if len(agg.SourceDirs) == 0 && len(agg.GitSources) == 0 {
+					logger.Fatalw("At least one of --configmap-source, --crd-source, the source dirs and the git sources is required.", zap.String("aggregation", agg.Name))
+				}
+			}
+		}
 
-	opts := []aggregator.Option{aggregator.WithInterval(interval), aggregator.WithLogger(logger)}
-	if appConfigLabel != "" {
-		opts = append(opts, aggregator.WithAppConfigLabel(appConfigLabel))
+		broadcaster := record.NewBroadcaster()
+		broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: client.CoreV1().Events("")})
+		defer broadcaster.Shutdown()
+		recorder := broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: "numalogic-config-aggregator"})
+		opts = append(opts, aggregator.WithEventRecorder(recorder))
+		if crdSource {
+			dynamicClient, err := dynamic.NewForConfig(restConfig)
+			if err != nil {
+				logger.Fatalw("Failed to create kubernetes dynamic client", zap.Error(err))
+			}
+			opts = append(opts, aggregator.WithAppConfigResourceSource(dynamicClient))
+		}
 	}
-	if configMapKey != "" {
-		opts = append(opts, aggregator.WithConfigMapKey(configMapKey))
+	var aggregators []aggregator.Aggregator
+	for _, agg := range aggregations {
+		aggregators = append(aggregators, newAggregator(client, namespace, agg, logger, opts...))
+	}
+	if metricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics.Handler())
+		if dryRun {
+			mux.HandleFunc("/dry-run", func(w http.ResponseWriter, _ *http.Request) {
+				diffs := []*aggregator.ConfigDiff{}
+				for _, a := range aggregators {
+					if diff := a.LastDiff(); diff != nil {
+						diffs = append(diffs, diff)
+					}
+				}
+				w.Header().Set("Content-Type", "application/json")
+				_ = json.NewEncoder(w).Encode(diffs)
+			})
+		}
+		go func() {
+			if err := http.ListenAndServe(metricsAddr, mux); err != nil {
+				logger.Fatalw("Failed to serve metrics", zap.Error(err))
+			}
+		}()
+	}
+	if healthAddr != "" {
+		var liveness, readiness []health.Check
+		for _, a := range aggregators {
+			liveness = append(liveness, a.Healthy)
+			readiness = append(readiness, a.Ready)
+		}
+		mux := http.NewServeMux()
+		mux.Handle("/healthz", health.Handler(liveness...))
+		mux.Handle("/readyz", health.Handler(readiness...))
+		go func() {
+			if err := http.ListenAndServe(healthAddr, mux); err != nil {
+				logger.Fatalw("Failed to serve health probes", zap.Error(err))
+			}
+		}()
 	}
-	a := aggregator.NewAggregator(client, namespace, configMapName, opts...)
-	elector := leaderelection.NewK8sLeaderElector(client, namespace, "numalogic-config-aggregator-lock", hostname)
 	ctx := ctrl.SetupSignalHandler()
+	if webhookAddr != "" {
+		var validators []webhook.Validator
+		for _, a := range aggregators {
+			validators = append(validators, a)
+		}
+		server, err := webhook.NewServer(webhook.Mode(webhookMode), logger.Named("webhook"), validators...)
+		if err != nil {
+			logger.Fatalw("Failed to create the webhook server", zap.Error(err))
+		}
+		go func() {
+			if err := server.Start(ctx, webhookAddr, webhookCertDir); err != nil {
+				logger.Fatalw("Failed to serve the webhook", zap.Error(err))
+			}
+		}()
+	}
+	run := func() {
+		var wg sync.WaitGroup
+		for _, a := range aggregators {
+			wg.Add(1)
+			go func(a aggregator.Aggregator) {
+				defer wg.Done()
+				a.Run(ctx)
+			}(a)
+		}
+		wg.Wait()
+	}
+	// A dry run doesn't take the lease from the aggregator publishing the aggregated ConfigMap.
+	if local || dryRun {
+		run()
+		return
+	}
+	elector := leaderelection.NewK8sLeaderElector(client, namespace, "numalogic-config-aggregator-lock", hostname)
 	elector.RunOrDie(ctx, leaderelection.LeaderCallbacks{
 		OnStartedLeading: func(_ context.Context) {
-			a.Run(ctx)
+			run()
 		},
 		OnStoppedLeading: func() {
 			logger.Fatalf("Leader lost: %s", hostname)
@@ -77,6 +318,106 @@ func main() {
 	})
 }
 
+// Create an aggregator for an aggregation definition on top of the common options.
+func newAggregator(client kubernetes.Interface, namespace string, agg config.Aggregation, logger *zap.SugaredLogger, commonOpts ...aggregator.Option) aggregator.Aggregator {
+	opts := append([]aggregator.Option{aggregator.WithName(agg.Name), aggregator.WithLogger(logger.With("aggregation", agg.Name)), aggregator.WithNamespaceMerge(agg.MergeNamespace), aggregator.WithNamespaced(agg.Namespaced)}, commonOpts...)
+	if agg.AppConfigLabel != "" {
+		opts = append(opts, aggregator.WithAppConfigLabel(agg.AppConfigLabel))
+	}
+	if agg.ConfigMapKey != "" {
+		opts = append(opts, aggregator.WithConfigMapKey(agg.ConfigMapKey))
+	}
+	if agg.SchemaFileDir != "" {
+		opts = append(opts, aggregator.WithSchemaFileDir(agg.SchemaFileDir))
+	}
+	if agg.Interval != nil {
+		opts = append(opts, aggregator.WithInterval(agg.Interval.Duration))
+	}
+	if agg.NamespaceSelector != "" {
+		opts = append(opts, aggregator.WithNamespaceSelector(agg.NamespaceSelector))
+	}
+	if len(agg.IncludeNamespaces) > 0 {
+		opts = append(opts, aggregator.WithIncludeNamespaces(agg.IncludeNamespaces))
+	}
+	if agg.ExcludeNamespaces != "" {
+		// It's validated with the config.
+		opts = append(opts, aggregator.WithExcludeNamespaces(regexp.MustCompile(agg.ExcludeNamespaces)))
+	}
+	maxConfigMapSize := aggregator.DefaultMaxConfigMapSize
+	if agg.MaxConfigMapSize != 0 {
+		maxConfigMapSize = agg.MaxConfigMapSize
+		opts = append(opts, aggregator.WithMaxConfigMapSize(agg.MaxConfigMapSize))
+	}
+	if agg.RevisionHistoryLimit != nil {
+		opts = append(opts, aggregator.WithRevisionHistoryLimit(*agg.RevisionHistoryLimit))
+	}
+	if agg.StaleConfigGracePeriod != nil {
+		opts = append(opts, aggregator.WithStaleConfigGracePeriod(agg.StaleConfigGracePeriod.Duration))
+	}
+	if agg.MaxRemovedPercent != nil || agg.MaxRemovedConfigs != nil {
+		percent, configs := aggregator.DefaultMaxRemovedPercent, 0
+		if agg.MaxRemovedPercent != nil {
+			percent = *agg.MaxRemovedPercent
+		}
+		if agg.MaxRemovedConfigs != nil {
+			configs = *agg.MaxRemovedConfigs
+		}
+		opts = append(opts, aggregator.WithMaxRemovals(percent, configs))
+	}
+	if agg.ConfigMapNamespace != "" {
+		namespace = agg.ConfigMapNamespace
+	}
+	for _, s := range agg.Sinks {
+		opts = append(opts, aggregator.WithSinks(newSink(client, namespace, agg, s, maxConfigMapSize)))
+	}
+	for _, dir := range agg.SourceDirs {
+		opts = append(opts, aggregator.WithSources(aggregator.NewDirSource(dir)))
+	}
+	for _, g := range agg.GitSources {
+		opts = append(opts, aggregator.WithSources(aggregator.NewGitSource(g.Repo, g.Ref, g.PathPattern)))
+	}
+	return aggregator.NewAggregator(client, namespace, agg.ConfigMapName, opts...)
+}
+
+// Create a sink of an aggregation, the namespace and the key fall back to the ones of the aggregated ConfigMap.
+func newSink(client kubernetes.Interface, namespace string, agg config.Aggregation, s config.Sink, maxConfigMapSize int) aggregator.Sink {
+	if s.Namespace != "" {
+		namespace = s.Namespace
+	}
+	key := s.Key
+	if key == "" {
+		key = agg.ConfigMapKey
+	}
+	if key == "" {
+		key = aggregator.DefaultConfigMapKey
+	}
+	switch s.Type {
+	case config.SinkTypeConfigMap:
+		return aggregator.NewConfigMapSink(client, namespace, s.Name, key, maxConfigMapSize)
+	case config.SinkTypeSecret:
+		return aggregator.NewSecretSink(client, namespace, s.Name, key)
+	case config.SinkTypeFile:
+		return aggregator.NewFileSink(s.Path)
+	case config.SinkTypeRedis:
+		// It's validated with the config.
+		opts, _ := redis.ParseURL(s.URL)
+		if s.PasswordEnv != "" {
+			opts.Password = os.Getenv(s.PasswordEnv)
+		}
+		prefix := s.KeyPrefix
+		if prefix == "" {
+			prefix = "numalogic-config-aggregator:" + agg.Name
+		}
+		return aggregator.NewRedisSink(redis.NewClient(opts), prefix, s.PerServiceKeys)
+	default: // It's validated with the config.
+		timeout := 10 * time.Second
+		if s.Timeout != nil {
+			timeout = s.Timeout.Duration
+		}
+		return aggregator.NewHTTPSink(s.URL, s.Headers, timeout)
+	}
+}
+
 func getClientConfig() (*rest.Config, error) {
 	kubeconfig, _ := os.LookupEnv("KUBECONFIG")
 	if kubeconfig != "" {
@@ -3,19 +3,25 @@ package aggregator
 import (
 	"context"
 	"fmt"
+	"regexp"
+	"sync"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/spf13/viper"
 	"github.com/xeipuuv/gojsonschema"
 	"go.uber.org/zap"
-	corev1 "k8s.io/api/core/v1"
-	apierrors "k8s.io/apimachinery/pkg/api/errors"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/yaml"
 
 	"github.com/numaproj-labs/numalogic-config-aggregator/pkg/logging"
+	"github.com/numaproj-labs/numalogic-config-aggregator/pkg/metrics"
 )
 
 var defaultSettings struct {
@@ -23,16 +29,32 @@ var defaultSettings struct {
 	configMapKey      string
 	appConfigMapLabel string
 	schemaFileDir     string
+	watch             bool
+	debounce          time.Duration
+	mergeNamespace    bool
+	statusAnnotations bool
+	configMapSource   bool
+	runTimeout        time.Duration
+	syncTimeout       time.Duration
 }
 
 func init() {
 	defaultSettings.interval = time.Second * 180
-	defaultSettings.configMapKey = "config.yaml"
+	defaultSettings.configMapKey = DefaultConfigMapKey
 	defaultSettings.appConfigMapLabel = "numaprom.numaproj.io/component=argo-rollouts"
 	defaultSettings.schemaFileDir = "/etc/config/config-aggregator"
+	defaultSettings.watch = true
+	defaultSettings.debounce = time.Second * 5
+	defaultSettings.mergeNamespace = false
+	defaultSettings.statusAnnotations = true
+	defaultSettings.configMapSource = true
+	defaultSettings.runTimeout = time.Minute * 10
+	defaultSettings.syncTimeout = time.Minute
 }
 
 type aggregator struct {
+	// Name of the aggregation, used in the logs and metrics
+	name      string
 	k8sclient kubernetes.Interface
 	// The namespace of the centralized configuration is located
 	namespace string
@@ -44,32 +66,136 @@ type aggregator struct {
 	appConfigLabel string
 	// The dir of the schema.json file for validation
 	schemaFileDir string
-	// Interval of each run
+	// The path of the json-schema file for validation, it overrides the schemaFileDir if it's set
+	schemaFile string
+	// Interval of each run, it's a resync interval when watch is enabled
 	interval time.Duration
-	logger   *zap.SugaredLogger
+	// Whether to watch the application ConfigMaps and aggregate on changes
+	watch bool
+	// The time to wait for more changes before running an aggregation triggered by events
+	debounce time.Duration
+	// Whether to merge the configs from multiple ConfigMaps in one namespace into one config per service
+	mergeNamespace bool
+	// Whether to annotate the application ConfigMaps with the aggregation result
+	statusAnnotations bool
+	// Whether to read the application configs from the labeled ConfigMaps
+	configMapSource bool
+	// The client to read the NumalogicAppConfig resources, they are not read if it's nil
+	dynamicClient dynamic.Interface
+	// The max size of the aggregated config in the ConfigMap before it's sharded
+	maxConfigMapSize int
+	// The sink of the aggregated ConfigMap
+	primarySink *configMapSink
+	// The additional sinks of the aggregated config, besides the aggregated ConfigMap
+	sinks []Sink
+	// The sources of the application configs, the labeled ConfigMaps and the NumalogicAppConfig resources
+	// are included according to configMapSource and dynamicClient
+	sources []Source
+	// The number of the revisions of the aggregated config to keep, no revision is kept if it's 0
+	revisionHistoryLimit int
+	// How long to keep the last valid version of an application config after it becomes invalid, it's not kept if it's 0
+	staleConfigGracePeriod time.Duration
+	// The last valid versions of the application configs, keyed by "<namespace>/<service>", nil before seeded
+	knownGood map[string]*knownGoodConfig
+	// The max percentage of the published application configs removed in one run, no limit if it's 0
+	maxRemovedPercent int
+	// The max number of the published application configs removed in one run, no limit if it's 0
+	maxRemovedConfigs int
+	// The application configs published to the aggregated ConfigMap, keyed by "<namespace>/<service>", nil before seeded
+	published map[string]bool
+	// The stale application configs expired since the last publish, they're not regarded as removals by the guard
+	expiredStale map[string]bool
+	// The message of the last event of the blocked removals, to not repeat it in every run
+	lastBlockedMessage string
+	// Whether to only compute the difference from the aggregated ConfigMap, without writing anything
+	dryRun bool
+	// The difference computed by the last dry run, nil before the first one
+	lastDiff *ConfigDiff
+	diffLock sync.RWMutex
+	// The hashes of the application config sources in the last revision, keyed by "<kind> <namespace>/<name>"
+	sourceHashes map[string]string
+	// The max duration of a run before the aggregator is considered unhealthy
+	runTimeout time.Duration
+	// The max duration to wait for an informer cache to sync before falling back to the periodical runs
+	syncTimeout time.Duration
+	// The label selector of the namespaces to read the application configs from, all namespaces if it's empty
+	namespaceSelector string
+	// The namespaces to read the application configs from, all namespaces if it's empty
+	includeNamespaces []string
+	// The regex of the namespaces not to read the application configs from
+	excludeNamespaces *regexp.Regexp
+	// Whether to list and watch the application configs in each of the included namespaces,
+	// instead of cluster wide, so it only requires the namespaced permissions
+	namespaced bool
+
+	logger *zap.SugaredLogger
+
+	// The recorder of the events on the application config sources
+	recorder record.EventRecorder
 
 	schemaLoader gojsonschema.JSONLoader
+	// The error of loading the schema, nil if it's loaded successfully
+	schemaErr  error
+	schemaLock sync.RWMutex
+	// The state of the run loop for the health checks
+	state runState
+
+	// The listers of the informers, one for each watched namespace
+	cmListers []corelisters.ConfigMapLister
+	acListers []cache.GenericLister
+	nsLister  corelisters.NamespaceLister
+	// Guards the nsLister, which is also read by the webhook
+	nsListerLock sync.RWMutex
+	// The last message of each event on the application config sources, keyed by "<kind>/<namespace>/<name>/<reason>"
+	lastEvents map[string]string
 }
 
 // NewAggregator returns an aggregator instance
 func NewAggregator(k8sclient kubernetes.Interface, namespace, configMap string, opts ...Option) *aggregator {
 	a := &aggregator{
-		k8sclient:      k8sclient,
-		namespace:      namespace,
-		configMap:      configMap,
-		configMapKey:   defaultSettings.configMapKey,
-		interval:       defaultSettings.interval,
-		appConfigLabel: defaultSettings.appConfigMapLabel,
-		schemaFileDir:  defaultSettings.schemaFileDir,
+		k8sclient:              k8sclient,
+		namespace:              namespace,
+		configMap:              configMap,
+		configMapKey:           defaultSettings.configMapKey,
+		interval:               defaultSettings.interval,
+		appConfigLabel:         defaultSettings.appConfigMapLabel,
+		schemaFileDir:          defaultSettings.schemaFileDir,
+		watch:                  defaultSettings.watch,
+		debounce:               defaultSettings.debounce,
+		mergeNamespace:         defaultSettings.mergeNamespace,
+		statusAnnotations:      defaultSettings.statusAnnotations,
+		configMapSource:        defaultSettings.configMapSource,
+		runTimeout:             defaultSettings.runTimeout,
+		syncTimeout:            defaultSettings.syncTimeout,
+		maxConfigMapSize:       DefaultMaxConfigMapSize,
+		revisionHistoryLimit:   DefaultRevisionHistoryLimit,
+		staleConfigGracePeriod: DefaultStaleConfigGracePeriod,
+		maxRemovedPercent:      DefaultMaxRemovedPercent,
+		lastEvents:             map[string]string{},
 	}
 	for _, opt := range opts {
 		if opt != nil {
 			opt(a)
 		}
 	}
+	if a.name == "" {
+		a.name = configMap
+	}
 	if a.logger == nil {
 		a.logger = logging.NewLogger()
 	}
+	var builtins []Source
+	if a.configMapSource {
+		builtins = append(builtins, &appConfigMapSource{a: a})
+	}
+	if a.dynamicClient != nil {
+		builtins = append(builtins, &appConfigResourceSource{a: a})
+	}
+	a.sources = append(builtins, a.sources...)
+	// There's no aggregated ConfigMap without a cluster, the aggregated config is only written to the sinks.
+	if k8sclient != nil {
+		a.primarySink = newConfigMapSink(k8sclient, namespace, configMap, a.configMapKey, a.maxConfigMapSize)
+	}
 	a.loadConfig()
 	return a
 }
@@ -77,29 +203,92 @@ func NewAggregator(k8sclient kubernetes.Interface, namespace, configMap string,
 // Auto reload schema.json
 func (a *aggregator) loadConfig() {
 	v := viper.New()
-	v.SetConfigName("schema")
-	v.SetConfigType("json")
-	v.AddConfigPath(a.schemaFileDir)
-	v.WatchConfig()
 	f := fmt.Sprintf("file://%s/schema.json", a.schemaFileDir)
+	if a.schemaFile != "" {
+		v.SetConfigFile(a.schemaFile)
+		f = "file://" + a.schemaFile
+	} else {
+		v.SetConfigName("schema")
+		v.SetConfigType("json")
+		v.AddConfigPath(a.schemaFileDir)
+	}
+	v.WatchConfig()
 	v.OnConfigChange(func(e fsnotify.Event) {
-		a.schemaLoader = gojsonschema.NewReferenceLoader(f)
+		a.setSchemaLoader(f)
 	})
-	a.schemaLoader = gojsonschema.NewReferenceLoader(f)
+	a.setSchemaLoader(f)
+}
+
+func (a *aggregator) setSchemaLoader(f string) {
+	loader := gojsonschema.NewReferenceLoader(f)
+	// Compile the schema to make sure it's loadable.
+	_, err := gojsonschema.NewSchema(loader)
+	if err != nil {
+		a.logger.Errorw("Failed to load the schema", zap.String("file", f), zap.Error(err))
+	}
+	a.schemaLock.Lock()
+	defer a.schemaLock.Unlock()
+	a.schemaLoader = loader
+	a.schemaErr = err
+}
+
+func (a *aggregator) getSchemaLoader() gojsonschema.JSONLoader {
+	a.schemaLock.RLock()
+	defer a.schemaLock.RUnlock()
+	return a.schemaLoader
 }
 
 // Run starts an infinite for loop to aggregate the config from applications namespaces,
 // it accepts a cancellable context as a parameter.
+//
+// When watch is enabled, the aggregation is triggered by the changes of the application
+// ConfigMaps, and the periodical run works as a resync.
 func (a *aggregator) Run(ctx context.Context) {
+	a.state.start()
+	defer a.state.stop()
+	trigger := make(chan struct{}, 1)
+	// Run once shortly after starting.
+	trigger <- struct{}{}
+	if a.watch {
+		if err := a.startInformer(ctx, trigger); err != nil {
+			a.logger.Errorw("Failed to start the informers, falling back to periodical runs for the failed ones", zap.Error(err))
+		}
+	}
 	ticker := time.NewTicker(a.interval)
 	defer ticker.Stop()
+	debounceTimer := time.NewTimer(a.debounce)
+	if !debounceTimer.Stop() {
+		<-debounceTimer.C
+	}
+	defer debounceTimer.Stop()
+	debouncing := false
+	run := func() {
+		start := time.Now()
+		a.state.runStarted(start)
+		metrics.RunsTotal.WithLabelValues(a.name).Inc()
+		err := a.runOnce(ctx)
+		if err != nil {
+			metrics.RunsFailedTotal.WithLabelValues(a.name).Inc()
+			a.logger.Error(err)
+		} else if !a.dryRun {
+			metrics.LastSuccessfulUpdate.WithLabelValues(a.name).SetToCurrentTime()
+		}
+		a.state.runFinished(err == nil)
+		metrics.RunDuration.WithLabelValues(a.name).Observe(time.Since(start).Seconds())
+	}
 	for {
 		select {
 		case <-ticker.C:
-			err := a.runOnce(ctx)
-			if err != nil {
-				a.logger.Error(err)
+			run()
+		case <-trigger:
+			if !debouncing {
+				debouncing = true
+				debounceTimer.Reset(a.debounce)
 			}
+		case <-debounceTimer.C:
+			debouncing = false
+			run()
+			ticker.Reset(a.interval)
 		case <-ctx.Done():
 			a.logger.Info("Shutting down...")
 			return
@@ -108,67 +297,174 @@ func (a *aggregator) Run(ctx context.Context) {
 }
 
 func (a *aggregator) runOnce(ctx context.Context) error {
-	cmList, err := a.k8sclient.CoreV1().ConfigMaps("").List(ctx, metav1.ListOptions{LabelSelector: a.appConfigLabel})
+	sources, err := a.listSources(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to list configmaps, %w", err)
+		return err
 	}
-	config := GlobalConfig{
-		Configs: []obj{},
+	config := a.aggregate(sources)
+	config.Provenance = a.provenance()
+	a.retainKnownGoodConfigs(ctx, sources, &config)
+	a.recordSourceMetrics(sources)
+	configBytes, err := yaml.Marshal(&config)
+	if err != nil {
+		return fmt.Errorf("failed to marshal configuration, %w", err)
 	}
-	for _, cm := range cmList.Items {
-		for key, data := range cm.Data { // Iterate all the key/value pairs in the configmap
-			// TODO: merge multiple entries in one ConfigMap.
-			// TODO: merge entries from multiple ConfinMaps in one namespace.
-			appConfig, err := a.convert(data)
-			if err != nil {
-				a.logger.Errorw("Invalid application config", zap.String("namespace", cm.Namespace), zap.String("configmap", cm.Name), zap.String("configmapKey", key), zap.Error(err))
-				continue
-			}
-			if len(appConfig) == 0 {
-				a.logger.Warnw("Empty application config", zap.String("namespace", cm.Namespace), zap.String("configmap", cm.Name), zap.String("configmapKey", key))
-				continue
+	metrics.PayloadSize.WithLabelValues(a.name).Set(float64(len(configBytes)))
+	metrics.MaxPayloadSize.WithLabelValues(a.name).Set(float64(a.maxConfigMapSize))
+	if a.maxConfigMapSize > 0 && len(configBytes) > a.maxConfigMapSize*8/10 {
+		a.logger.Warnw("The aggregated config is approaching or exceeding the max size of a ConfigMap, it's sharded if it exceeds", zap.Int("size", len(configBytes)), zap.Int("maxSize", a.maxConfigMapSize))
+	}
+	if a.dryRun {
+		return a.runDry(ctx, configBytes)
+	}
+	if a.primarySink != nil {
+		allowed, err := a.checkRemovals(ctx, config)
+		if err != nil {
+			return err
+		}
+		if config, configBytes, err = a.writePrimarySink(ctx, sources, config, configBytes); err != nil {
+			return err
+		}
+		a.setPublished(config)
+		if allowed {
+			if err := a.consumeMassRemovalAllowance(ctx); err != nil {
+				a.logger.Errorw("Failed to remove the annotation allowing the mass removal", zap.Error(err))
 			}
-			appConfig[Namespace] = cm.Namespace
-			config.Configs = append(config.Configs, appConfig)
 		}
 	}
-	configBytes, err := yaml.Marshal(&config)
+	// The failures of the additional sinks do not block the others.
+	var sinkErrs []error
+	for _, sink := range a.sinks {
+		if _, err := a.writeSink(ctx, sink, config, configBytes); err != nil {
+			sinkErrs = append(sinkErrs, err)
+		}
+	}
+	a.recordSourceEvents(sources)
+	a.updateSourceStatuses(ctx, sources)
+	if len(sinkErrs) > 0 {
+		return utilerrors.NewAggregate(sinkErrs)
+	}
+	return nil
+}
+
+func (a *aggregator) writeSink(ctx context.Context, sink Sink, config GlobalConfig, content []byte) (bool, error) {
+	changed, err := sink.Write(ctx, config, content)
 	if err != nil {
-		return fmt.Errorf("failed to marshal configuration, %w", err)
+		metrics.SinkWritesFailedTotal.WithLabelValues(a.name, sink.String()).Inc()
+		a.logger.Errorw("Failed to write the aggregated config", zap.String("sink", sink.String()), zap.Error(err))
+		return false, fmt.Errorf("failed to write to %s, %w", sink, err)
+	}
+	if changed {
+		a.logger.Infow("Config changes saved successfully.", zap.String("sink", sink.String()))
+	} else {
+		a.logger.Infow("No config changes.", zap.String("sink", sink.String()))
 	}
-	cm, err := a.k8sclient.CoreV1().ConfigMaps(a.namespace).Get(ctx, a.configMap, metav1.GetOptions{})
+	return changed, nil
+}
+
+// Write the aggregated config to the aggregated ConfigMap, and record a revision if it's changed. If the
+// aggregated ConfigMap is pinned to a revision, the config of the revision is written and returned instead.
+func (a *aggregator) writePrimarySink(ctx context.Context, sources []*appSource, config GlobalConfig, configBytes []byte) (GlobalConfig, []byte, error) {
+	pinned, err := a.pinnedRevision(ctx)
 	if err != nil {
-		if apierrors.IsNotFound(err) {
-			cm = &corev1.ConfigMap{
-				ObjectMeta: metav1.ObjectMeta{
-					Namespace: a.namespace,
-					Name:      a.configMap,
-					Annotations: map[string]string{
-						"app.kubernetes.io/managed-by": "numalogic-config-aggregator",
-					},
-				},
-				Data: map[string]string{
-					a.configMapKey: string(configBytes),
-				},
-			}
-			if _, err := a.k8sclient.CoreV1().ConfigMaps(a.namespace).Create(ctx, cm, metav1.CreateOptions{}); err != nil {
-				return fmt.Errorf("failed to create aggregated configmap, %w", err)
+		return config, configBytes, err
+	}
+	if pinned > 0 {
+		// The aggregated config is rolled back to the pinned revision until it's unpinned.
+		a.logger.Warnw("The aggregated config is pinned to a revision", zap.Int("revision", pinned))
+		if configBytes, err = readRevision(ctx, a.k8sclient, a.namespace, a.configMap, a.configMapKey, pinned); err != nil {
+			return config, configBytes, err
+		}
+		config = GlobalConfig{}
+		if err := yaml.Unmarshal(configBytes, &config); err != nil {
+			return config, configBytes, fmt.Errorf("failed to parse revision %d, %w", pinned, err)
+		}
+	}
+	changed, err := a.writeSink(ctx, a.primarySink, config, configBytes)
+	if err != nil {
+		return config, configBytes, err
+	}
+	metrics.Shards.WithLabelValues(a.name).Set(float64(a.primarySink.shards))
+	if pinned == 0 {
+		changedSources, hashes := a.changedSources(sources)
+		if changed && a.revisionHistoryLimit > 0 {
+			if err := a.recordRevision(ctx, configBytes, changedSources); err != nil {
+				a.logger.Errorw("Failed to record a revision of the aggregated config", zap.Error(err))
 			}
-		} else {
-			return fmt.Errorf("failed to get aggregated configmap, %w", err)
 		}
+		a.sourceHashes = hashes
 	}
-	if string(configBytes) != cm.Data[a.configMapKey] {
-		cm.Data[a.configMapKey] = string(configBytes)
-		if _, err := a.k8sclient.CoreV1().ConfigMaps(a.namespace).Update(ctx, cm, metav1.UpdateOptions{}); err != nil {
-			return fmt.Errorf("failed to update aggregated configmap, %w", err)
-		} else {
-			a.logger.Info("Config changes saved successfully.")
+	return config, configBytes, nil
+}
+
+// List all the application config sources.
+func (a *aggregator) listSources(ctx context.Context) ([]*appSource, error) {
+	sources := []*appSource{}
+	for _, source := range a.sources {
+		configs, err := source.List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range configs {
+			sources = append(sources, newAppSource(c))
+		}
+	}
+	return a.filterSources(ctx, sources)
+}
+
+// The versions of the versioned sources.
+func (a *aggregator) provenance() []Provenance {
+	var result []Provenance
+	for _, source := range a.sources {
+		if v, ok := source.(VersionedSource); ok && v.Version() != "" {
+			result = append(result, Provenance{Source: source.String(), Version: v.Version()})
+		}
+	}
+	return result
+}
+
+// Aggregate the configs from the application config sources, the aggregation result
+// of each source is recorded in the source.
+func (a *aggregator) aggregate(sources []*appSource) GlobalConfig {
+	config := GlobalConfig{
+		Configs: []obj{},
+	}
+	if a.mergeNamespace {
+		sortByPrecedence(sources)
+		for start := 0; start < len(sources); {
+			end := start + 1
+			for end < len(sources) && sources[end].namespace == sources[start].namespace {
+				end++
+			}
+			for _, appConfig := range a.mergeNamespaceConfigs(sources[start:end]) {
+				appConfig[Namespace] = sources[start].namespace
+				config.Configs = append(config.Configs, appConfig)
+			}
+			start = end
 		}
 	} else {
-		a.logger.Info("No config changes.")
+		sortSources(sources)
+		for _, src := range sources {
+			for _, appConfig := range a.convertSource(src) {
+				src.accepted = append(src.accepted, appConfig)
+				appConfig[Namespace] = src.namespace
+				config.Configs = append(config.Configs, appConfig)
+			}
+		}
+	}
+	for _, appConfig := range config.Configs {
+		canonicalize(appConfig)
+	}
+	return config
+}
+
+// Record the metrics of the application config sources discovered in a run.
+func (a *aggregator) recordSourceMetrics(sources []*appSource) {
+	metrics.SourcesDiscovered.WithLabelValues(a.name).Set(float64(len(sources)))
+	metrics.AppConfigs.DeletePartialMatch(prometheus.Labels{metrics.LabelAggregation: a.name})
+	for _, src := range sources {
+		metrics.AppConfigs.WithLabelValues(a.name, src.namespace, src.status()).Inc()
 	}
-	return nil
 }
 
 // Validate the user configured YAML string, and convert to an object
@@ -179,7 +475,7 @@ func (a *aggregator) convert(config string) (obj, error) {
 		return nil, fmt.Errorf("invalid config, %w", err)
 	}
 	jsonLoader := gojsonschema.NewBytesLoader(jsonBytes)
-	result, err := gojsonschema.Validate(a.schemaLoader, jsonLoader)
+	result, err := gojsonschema.Validate(a.getSchemaLoader(), jsonLoader)
 	if err != nil {
 		return nil, fmt.Errorf("failed to validate application config, %w", err)
 	}
@@ -6,12 +6,17 @@ import (
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/watch"
 	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 	"sigs.k8s.io/yaml"
+
+	"github.com/numaproj-labs/numalogic-config-aggregator/pkg/metrics"
 )
 
 func Test_NewAggregator(t *testing.T) {
@@ -25,6 +30,9 @@ func Test_NewAggregator(t *testing.T) {
 		assert.Equal(t, defaultSettings.interval, a.interval)
 		assert.Equal(t, defaultSettings.configMapKey, a.configMapKey)
 		assert.Equal(t, defaultSettings.appConfigMapLabel, a.appConfigLabel)
+		assert.Equal(t, "cm", a.name)
+		assert.True(t, a.watch)
+		assert.Equal(t, defaultSettings.debounce, a.debounce)
 	})
 
 	t.Run("customized", func(t *testing.T) {
@@ -35,6 +43,7 @@ func Test_NewAggregator(t *testing.T) {
 		assert.Equal(t, time.Second*100, a.interval)
 		assert.Equal(t, "a.yaml", a.configMapKey)
 		assert.Equal(t, "a=b", a.appConfigLabel)
+		assert.False(t, a.mergeNamespace)
 	})
 }
 
@@ -79,6 +88,112 @@ func Test_runOnce(t *testing.T) {
 	assert.Equal(t, 2, len(uc1))
 }
 
+func Test_runOnce_noChanges(t *testing.T) {
+	namespace := "test-ns"
+	cm := "test-cm"
+	k8sCli := k8sfake.NewSimpleClientset()
+	for _, ns := range []string{"ns2", "ns1"} {
+		c := fakeAppConfigMap(t, ns, "n1")
+		c.Data["world"] = applicationConfigStr
+		_, _ = k8sCli.CoreV1().ConfigMaps(ns).Create(context.Background(), c, metav1.CreateOptions{})
+	}
+	path, err := os.Getwd()
+	assert.NoError(t, err)
+	a := NewAggregator(k8sCli, namespace, cm, WithSchemaFileDir(path+"/../../manifests/install/base"))
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, a.runOnce(context.Background()))
+	}
+	updates := 0
+	for _, action := range k8sCli.Actions() {
+		if action.GetVerb() == "update" {
+			updates++
+		}
+	}
+	assert.Equal(t, 0, updates)
+}
+
+func Test_runOnce_metrics(t *testing.T) {
+	k8sCli := k8sfake.NewSimpleClientset()
+	valid := fakeAppConfigMap(t, "ns1", "n1")
+	invalid := fakeAppConfigMap(t, "ns1", "n2")
+	invalid.Data["hello"] = "service: [oops"
+	_, _ = k8sCli.CoreV1().ConfigMaps("ns1").Create(context.Background(), valid, metav1.CreateOptions{})
+	_, _ = k8sCli.CoreV1().ConfigMaps("ns1").Create(context.Background(), invalid, metav1.CreateOptions{})
+	path, err := os.Getwd()
+	assert.NoError(t, err)
+	a := NewAggregator(k8sCli, "test-ns", "test-cm", WithName("test-metrics"), WithSchemaFileDir(path+"/../../manifests/install/base"))
+	assert.NoError(t, a.runOnce(context.Background()))
+	assert.Equal(t, float64(2), testutil.ToFloat64(metrics.SourcesDiscovered.WithLabelValues("test-metrics")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.AppConfigs.WithLabelValues("test-metrics", "ns1", StatusValid)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.AppConfigs.WithLabelValues("test-metrics", "ns1", StatusInvalid)))
+	assert.Greater(t, testutil.ToFloat64(metrics.PayloadSize.WithLabelValues("test-metrics")), float64(0))
+
+	assert.NoError(t, k8sCli.CoreV1().ConfigMaps("ns1").Delete(context.Background(), "n2", metav1.DeleteOptions{}))
+	assert.NoError(t, a.runOnce(context.Background()))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.SourcesDiscovered.WithLabelValues("test-metrics")))
+	assert.False(t, metrics.AppConfigs.DeleteLabelValues("test-metrics", "ns1", StatusInvalid))
+}
+
+func Test_Run_watch(t *testing.T) {
+	namespace := "test-ns"
+	cm := "test-cm"
+	k8sCli := k8sfake.NewSimpleClientset()
+	// The fake clientset does not replay the events between List and Watch, wait for the watch to be started.
+	watchStarted := make(chan struct{})
+	k8sCli.PrependWatchReactor("configmaps", func(action k8stesting.Action) (bool, watch.Interface, error) {
+		w, err := k8sCli.Tracker().Watch(action.GetResource(), action.GetNamespace())
+		if err != nil {
+			return false, nil, err
+		}
+		close(watchStarted)
+		return true, w, nil
+	})
+	path, err := os.Getwd()
+	assert.NoError(t, err)
+	a := NewAggregator(k8sCli, namespace, cm, WithSchemaFileDir(path+"/../../manifests/install/base"), WithInterval(time.Hour), WithDebounce(100*time.Millisecond))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, err = k8sCli.CoreV1().ConfigMaps("ns1").Create(ctx, fakeAppConfigMap(t, "ns1", "n1"), metav1.CreateOptions{})
+	assert.NoError(t, err)
+	go a.Run(ctx)
+
+	getConfig := func() GlobalConfig {
+		var c GlobalConfig
+		configMap, err := k8sCli.CoreV1().ConfigMaps(namespace).Get(ctx, cm, metav1.GetOptions{})
+		if err != nil {
+			return c
+		}
+		_ = yaml.Unmarshal([]byte(configMap.Data[defaultSettings.configMapKey]), &c)
+		return c
+	}
+
+	assert.Eventually(t, func() bool { return len(getConfig().Configs) == 1 }, 5*time.Second, 50*time.Millisecond)
+	<-watchStarted
+
+	_, err = k8sCli.CoreV1().ConfigMaps("ns2").Create(ctx, fakeAppConfigMap(t, "ns2", "n2"), metav1.CreateOptions{})
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool { return len(getConfig().Configs) == 2 }, 5*time.Second, 50*time.Millisecond)
+
+	err = k8sCli.CoreV1().ConfigMaps("ns1").Delete(ctx, "n1", metav1.DeleteOptions{})
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		c := getConfig()
+		return len(c.Configs) == 1 && c.Configs[0][Namespace] == "ns2"
+	}, 5*time.Second, 50*time.Millisecond)
+}
+
+func Test_configMapChanged(t *testing.T) {
+	cm1 := fakeAppConfigMap(t, "ns1", "n1")
+	cm1.ResourceVersion = "1"
+	cm2 := cm1.DeepCopy()
+	assert.False(t, configMapChanged(cm1, cm2))
+	cm2.ResourceVersion = "2"
+	cm2.Annotations = map[string]string{"a": "b"}
+	assert.False(t, configMapChanged(cm1, cm2))
+	cm2.Data["hello"] = "world"
+	assert.True(t, configMapChanged(cm1, cm2))
+}
+
 func fakeAppConfigMap(t *testing.T, ns, name string) *corev1.ConfigMap {
 	t.Helper()
 	l, _ := labels.ConvertSelectorToLabelsMap(defaultSettings.appConfigMapLabel)
@@ -93,3 +208,51 @@ func fakeAppConfigMap(t *testing.T, ns, name string) *corev1.ConfigMap {
 		},
 	}
 }
+
+func Test_health(t *testing.T) {
+	path, err := os.Getwd()
+	assert.NoError(t, err)
+	a := NewAggregator(k8sfake.NewSimpleClientset(), "test-ns", "test-cm", WithSchemaFileDir(path+"/../../manifests/install/base"), WithRunTimeout(time.Minute))
+	assert.NoError(t, a.Ready())
+	assert.NoError(t, a.Healthy())
+
+	a.state.start()
+	assert.Error(t, a.Ready())
+	a.state.runStarted(time.Now().Add(-2 * time.Minute))
+	assert.Error(t, a.Healthy())
+	a.state.runFinished(true)
+	assert.NoError(t, a.Ready())
+	assert.NoError(t, a.Healthy())
+
+	b := NewAggregator(k8sfake.NewSimpleClientset(), "test-ns", "test-cm", WithSchemaFileDir(path+"/not-existing"))
+	assert.Error(t, b.Ready())
+
+	// The loop is stuck before its first run.
+	c := NewAggregator(k8sfake.NewSimpleClientset(), "test-ns", "test-cm", WithSchemaFileDir(path+"/../../manifests/install/base"), WithRunTimeout(time.Minute), WithInterval(time.Minute))
+	c.state.start()
+	assert.NoError(t, c.Healthy())
+	c.state.startedAt = time.Now().Add(-3 * time.Minute)
+	assert.Error(t, c.Healthy())
+	// No run started for a long time after the last one.
+	c.state.runStarted(time.Now().Add(-time.Minute))
+	c.state.runFinished(true)
+	assert.NoError(t, c.Healthy())
+	c.state.runStarted(time.Now().Add(-3 * time.Minute))
+	c.state.runFinished(true)
+	assert.Error(t, c.Healthy())
+}
+
+func Test_Run_ready(t *testing.T) {
+	path, err := os.Getwd()
+	assert.NoError(t, err)
+	k8sCli := k8sfake.NewSimpleClientset()
+	a := NewAggregator(k8sCli, "test-ns", "test-cm", WithSchemaFileDir(path+"/../../manifests/install/base"), WithWatch(false), WithInterval(time.Hour), WithDebounce(10*time.Millisecond))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go a.Run(ctx)
+	// The first run happens without waiting for the interval.
+	assert.Eventually(t, func() bool {
+		_, err := k8sCli.CoreV1().ConfigMaps("test-ns").Get(ctx, "test-cm", metav1.GetOptions{})
+		return err == nil && a.Ready() == nil
+	}, 5*time.Second, 50*time.Millisecond)
+}
@@ -0,0 +1,55 @@
+package aggregator
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Lists nested in the items of the application config lists, of which the order is not meaningful.
+var unorderedNestedLists = map[string][]string{
+	"unified_configs": {"unified_metrics"},
+}
+
+// Sort the sources by namespace, kind and name.
+func sortSources(sources []*appSource) {
+	sort.SliceStable(sources, func(i, j int) bool {
+		if sources[i].namespace != sources[j].namespace {
+			return sources[i].namespace < sources[j].namespace
+		}
+		if sources[i].kind != sources[j].kind {
+			return sources[i].kind < sources[j].kind
+		}
+		return sources[i].name < sources[j].name
+	})
+}
+
+// Canonicalize the application config in place, the lists of which the order is not meaningful are sorted,
+// so that the same config always produces the same output.
+func canonicalize(config obj) {
+	for k, idField := range listItemIdentities {
+		items, ok := config[k].([]interface{})
+		if !ok {
+			continue
+		}
+		sort.SliceStable(items, func(i, j int) bool {
+			idi, _ := itemIdentity(items[i], idField)
+			idj, _ := itemIdentity(items[j], idField)
+			return idi < idj
+		})
+		for _, nested := range unorderedNestedLists[k] {
+			for _, item := range items {
+				if o, ok := item.(obj); ok {
+					if l, ok := o[nested].([]interface{}); ok {
+						sortScalars(l)
+					}
+				}
+			}
+		}
+	}
+}
+
+func sortScalars(l []interface{}) {
+	sort.SliceStable(l, func(i, j int) bool {
+		return fmt.Sprint(l[i]) < fmt.Sprint(l[j])
+	})
+}
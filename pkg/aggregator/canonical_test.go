@@ -0,0 +1,46 @@
+package aggregator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/yaml"
+)
+
+func Test_sortSources(t *testing.T) {
+	sources := []*appSource{
+		newConfigMapSource(fakeAppConfigMap(t, "ns2", "a")),
+		newConfigMapSource(fakeAppConfigMap(t, "ns1", "b")),
+		newAppConfigResourceSource(fakeAppConfigResource(t, "ns1", "a")),
+		newConfigMapSource(fakeAppConfigMap(t, "ns1", "a")),
+	}
+	sortSources(sources)
+	names := []string{}
+	for _, src := range sources {
+		names = append(names, src.namespace+"/"+src.kind+"/"+src.name)
+	}
+	assert.Equal(t, []string{"ns1/ConfigMap/a", "ns1/ConfigMap/b", "ns1/NumalogicAppConfig/a", "ns2/ConfigMap/a"}, names)
+}
+
+func Test_canonicalize(t *testing.T) {
+	shuffled := `service: test
+metric_configs:
+- metric: m2
+  composite_keys: [ck21, ck22]
+  static_threshold: 2
+- metric: m1
+  composite_keys: [ck11, ck12]
+  static_threshold: 1
+unified_configs:
+- unified_metric_name: umn2
+  unified_metrics: [um22, um21]
+- unified_metric_name: umn1
+  unified_metrics: [um12, um11]
+`
+	var c obj
+	assert.NoError(t, yaml.Unmarshal([]byte(shuffled), &c))
+	canonicalize(c)
+	yamlBytes, err := yaml.Marshal(&c)
+	assert.NoError(t, err)
+	assert.Equal(t, applicationConfigStr, string(yamlBytes))
+}
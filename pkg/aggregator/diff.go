@@ -0,0 +1,208 @@
+package aggregator
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"go.uber.org/zap"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/numaproj-labs/numalogic-config-aggregator/pkg/metrics"
+)
+
+const (
+	ChangeAdded   = "Added"
+	ChangeRemoved = "Removed"
+	ChangeChanged = "Changed"
+)
+
+// ConfigDiff is the difference between the published aggregated config and a new one.
+type ConfigDiff struct {
+	// Name of the aggregation
+	Aggregation string `json:"aggregation"`
+	// Namespaces only in the new aggregated config
+	AddedNamespaces []string `json:"addedNamespaces,omitempty"`
+	// Namespaces only in the published aggregated config
+	RemovedNamespaces []string `json:"removedNamespaces,omitempty"`
+	// The added, removed and changed services
+	Services []ServiceDiff `json:"services,omitempty"`
+	// The revision the aggregated ConfigMap is pinned to, the new aggregated config would not be published until it's unpinned
+	PinnedRevision int `json:"pinnedRevision,omitempty"`
+	// Why the removal guard would refuse to publish the new aggregated config, empty if it would be published
+	Blocked string `json:"blocked,omitempty"`
+}
+
+// ServiceDiff is the difference of the config of a service.
+type ServiceDiff struct {
+	Namespace string `json:"namespace"`
+	Service   string `json:"service"`
+	// Added, Removed or Changed
+	Change string `json:"change"`
+	// The top level fields added, removed or changed, only for a changed service
+	Fields []string `json:"fields,omitempty"`
+}
+
+// Empty returns whether there's no difference.
+func (d ConfigDiff) Empty() bool {
+	return len(d.AddedNamespaces) == 0 && len(d.RemovedNamespaces) == 0 && len(d.Services) == 0
+}
+
+// Count returns the number of the services with the change.
+func (d ConfigDiff) Count(change string) int {
+	n := 0
+	for _, s := range d.Services {
+		if s.Change == change {
+			n++
+		}
+	}
+	return n
+}
+
+// Compute the difference between the aggregated ConfigMap and the new aggregated config, and log it,
+// nothing is written. Whether a real run would publish it, i.e. it's not pinned or blocked by the removal
+// guard, is also computed.
+func (a *aggregator) runDry(ctx context.Context, configBytes []byte) error {
+	current := GlobalConfig{}
+	if a.primarySink != nil {
+		var err error
+		if current, _, err = a.primarySink.read(ctx); err != nil {
+			return err
+		}
+	}
+	// Compare the parsed contents, so the values are typed in the same way.
+	config := GlobalConfig{}
+	if err := yaml.Unmarshal(configBytes, &config); err != nil {
+		return fmt.Errorf("failed to parse configuration, %w", err)
+	}
+	diff := diffConfigs(current, config)
+	diff.Aggregation = a.name
+	if a.primarySink != nil {
+		var err error
+		if diff.PinnedRevision, diff.Blocked, err = a.dryRunPublishable(ctx, current, config); err != nil {
+			return err
+		}
+	}
+	for _, change := range []string{ChangeAdded, ChangeRemoved, ChangeChanged} {
+		metrics.DryRunChanges.WithLabelValues(a.name, change).Set(float64(diff.Count(change)))
+	}
+	a.diffLock.Lock()
+	a.lastDiff = &diff
+	a.diffLock.Unlock()
+	if diff.Empty() {
+		a.logger.Info("Dry run, the aggregated config is not changed")
+		return nil
+	}
+	switch {
+	case diff.PinnedRevision > 0:
+		a.logger.Infow("Dry run, the aggregated config would be changed, but it's pinned to a revision", zap.Int("revision", diff.PinnedRevision), zap.Strings("addedNamespaces", diff.AddedNamespaces), zap.Strings("removedNamespaces", diff.RemovedNamespaces), zap.Any("services", diff.Services))
+	case diff.Blocked != "":
+		a.logger.Infow("Dry run, the aggregated config would be changed, but it's blocked by the removal guard", zap.String("blocked", diff.Blocked), zap.Strings("addedNamespaces", diff.AddedNamespaces), zap.Strings("removedNamespaces", diff.RemovedNamespaces), zap.Any("services", diff.Services))
+	default:
+		a.logger.Infow("Dry run, the aggregated config would be changed", zap.Strings("addedNamespaces", diff.AddedNamespaces), zap.Strings("removedNamespaces", diff.RemovedNamespaces), zap.Any("services", diff.Services))
+	}
+	return nil
+}
+
+// The revision the aggregated ConfigMap is pinned to, and the message of the removal guard if it would refuse to
+// publish the new aggregated config, in the same way as a real run, without the side effects of the guard.
+func (a *aggregator) dryRunPublishable(ctx context.Context, current, config GlobalConfig) (int, string, error) {
+	pinned, err := a.pinnedRevision(ctx)
+	if err != nil || pinned > 0 {
+		return pinned, "", err
+	}
+	published := publishedKeys(current)
+	removed, exceeded := a.exceedsRemovalLimits(published, config)
+	if !exceeded {
+		return 0, "", nil
+	}
+	cm, err := a.k8sclient.CoreV1().ConfigMaps(a.namespace).Get(ctx, a.configMap, metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return 0, "", fmt.Errorf("failed to get aggregated configmap, %w", err)
+	}
+	if err == nil && cm.Annotations[AllowMassRemovalAnnotation] == "true" {
+		return 0, "", nil
+	}
+	return 0, blockedMessage(removed, len(published)), nil
+}
+
+func (a *aggregator) LastDiff() *ConfigDiff {
+	a.diffLock.RLock()
+	defer a.diffLock.RUnlock()
+	return a.lastDiff
+}
+
+// Compute the difference from the old aggregated config to the new one, the services are identified by their
+// namespaces and names.
+func diffConfigs(old, new GlobalConfig) ConfigDiff {
+	oldConfigs, oldNamespaces := indexConfigs(old)
+	newConfigs, newNamespaces := indexConfigs(new)
+	diff := ConfigDiff{}
+	for ns := range newNamespaces {
+		if !oldNamespaces[ns] {
+			diff.AddedNamespaces = append(diff.AddedNamespaces, ns)
+		}
+	}
+	for ns := range oldNamespaces {
+		if !newNamespaces[ns] {
+			diff.RemovedNamespaces = append(diff.RemovedNamespaces, ns)
+		}
+	}
+	for key, c := range newConfigs {
+		o, ok := oldConfigs[key]
+		if !ok {
+			diff.Services = append(diff.Services, ServiceDiff{Namespace: key[0], Service: key[1], Change: ChangeAdded})
+			continue
+		}
+		if fields := diffFields(o, c); len(fields) > 0 {
+			diff.Services = append(diff.Services, ServiceDiff{Namespace: key[0], Service: key[1], Change: ChangeChanged, Fields: fields})
+		}
+	}
+	for key := range oldConfigs {
+		if _, ok := newConfigs[key]; !ok {
+			diff.Services = append(diff.Services, ServiceDiff{Namespace: key[0], Service: key[1], Change: ChangeRemoved})
+		}
+	}
+	sort.Strings(diff.AddedNamespaces)
+	sort.Strings(diff.RemovedNamespaces)
+	sort.Slice(diff.Services, func(i, j int) bool {
+		if diff.Services[i].Namespace != diff.Services[j].Namespace {
+			return diff.Services[i].Namespace < diff.Services[j].Namespace
+		}
+		return diff.Services[i].Service < diff.Services[j].Service
+	})
+	return diff
+}
+
+// Index the configs by the namespace and the service, and list the namespaces.
+func indexConfigs(config GlobalConfig) (map[[2]string]obj, map[string]bool) {
+	configs := map[[2]string]obj{}
+	namespaces := map[string]bool{}
+	for _, c := range config.Configs {
+		ns, _ := c[Namespace].(string)
+		service, _ := c["service"].(string)
+		configs[[2]string{ns, service}] = c
+		namespaces[ns] = true
+	}
+	return configs, namespaces
+}
+
+// The sorted top level fields different between the two configs.
+func diffFields(old, new obj) []string {
+	var fields []string
+	for k, v := range new {
+		if ov, ok := old[k]; !ok || !reflect.DeepEqual(ov, v) {
+			fields = append(fields, k)
+		}
+	}
+	for k := range old {
+		if _, ok := new[k]; !ok {
+			fields = append(fields, k)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
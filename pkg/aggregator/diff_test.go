@@ -0,0 +1,107 @@
+package aggregator
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/yaml"
+
+	"github.com/numaproj-labs/numalogic-config-aggregator/pkg/metrics"
+)
+
+func Test_diffConfigs(t *testing.T) {
+	old := GlobalConfig{Configs: []obj{
+		{Namespace: "ns1", "service": "a", "metric_configs": []interface{}{"m1"}},
+		{Namespace: "ns1", "service": "b", "metric_configs": []interface{}{"m1"}, "unified_configs": []interface{}{"u1"}},
+		{Namespace: "ns2", "service": "a"},
+	}}
+	new := GlobalConfig{Configs: []obj{
+		{Namespace: "ns1", "service": "b", "metric_configs": []interface{}{"m2"}},
+		{Namespace: "ns1", "service": "a", "metric_configs": []interface{}{"m1"}},
+		{Namespace: "ns3", "service": "a"},
+	}}
+	diff := diffConfigs(old, new)
+	assert.Equal(t, []string{"ns3"}, diff.AddedNamespaces)
+	assert.Equal(t, []string{"ns2"}, diff.RemovedNamespaces)
+	assert.Equal(t, []ServiceDiff{
+		{Namespace: "ns1", Service: "b", Change: ChangeChanged, Fields: []string{"metric_configs", "unified_configs"}},
+		{Namespace: "ns2", Service: "a", Change: ChangeRemoved},
+		{Namespace: "ns3", Service: "a", Change: ChangeAdded},
+	}, diff.Services)
+	assert.True(t, diffConfigs(old, old).Empty())
+}
+
+func Test_runOnce_dryRun(t *testing.T) {
+	ctx := context.Background()
+	path, err := os.Getwd()
+	assert.NoError(t, err)
+	seed, err := yaml.Marshal(fakeGlobalConfig(t))
+	assert.NoError(t, err)
+	k8sCli := k8sfake.NewSimpleClientset(&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "test-cm"}, Data: map[string]string{defaultSettings.configMapKey: string(seed)}})
+	changed := fakeAppConfigMap(t, "ns1", "n1")
+	changed.Data["hello"] = strings.Replace(applicationConfigStr, "static_threshold: 1", "static_threshold: 3", 1)
+	for _, cm := range []*corev1.ConfigMap{changed, fakeAppConfigMap(t, "ns2", "n1")} {
+		_, err := k8sCli.CoreV1().ConfigMaps(cm.Namespace).Create(ctx, cm, metav1.CreateOptions{})
+		assert.NoError(t, err)
+	}
+	a := NewAggregator(k8sCli, "test-ns", "test-cm", WithName("test-dry-run"), WithSchemaFileDir(path+"/../../manifests/install/base"), WithDryRun(true))
+	assert.Nil(t, a.LastDiff())
+	assert.NoError(t, a.runOnce(ctx))
+
+	diff := a.LastDiff()
+	assert.NotNil(t, diff)
+	assert.Equal(t, "test-dry-run", diff.Aggregation)
+	assert.Equal(t, []string{"ns2"}, diff.AddedNamespaces)
+	assert.Equal(t, []ServiceDiff{
+		{Namespace: "ns1", Service: "test", Change: ChangeChanged, Fields: []string{"metric_configs"}},
+		{Namespace: "ns2", Service: "test", Change: ChangeAdded},
+	}, diff.Services)
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.DryRunChanges.WithLabelValues("test-dry-run", ChangeAdded)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.DryRunChanges.WithLabelValues("test-dry-run", ChangeChanged)))
+
+	// Nothing is written.
+	cm, err := k8sCli.CoreV1().ConfigMaps("test-ns").Get(ctx, "test-cm", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, string(seed), cm.Data[defaultSettings.configMapKey])
+	revisions, err := ListRevisions(ctx, k8sCli, "test-ns", "test-cm")
+	assert.NoError(t, err)
+	assert.Empty(t, revisions)
+	appCm, err := k8sCli.CoreV1().ConfigMaps("ns1").Get(ctx, "n1", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.NotContains(t, appCm.Annotations, StatusAnnotation)
+	assert.Empty(t, diff.Blocked)
+	assert.Zero(t, diff.PinnedRevision)
+
+	// Removing all the published configs would be blocked by the removal guard.
+	published := fakeGlobalConfig(t)
+	other := fakeApplicationConfig(t)
+	other[Namespace] = "ns3"
+	published.Configs = append(published.Configs, other)
+	publishedBytes, err := yaml.Marshal(&published)
+	assert.NoError(t, err)
+	cm.Data[defaultSettings.configMapKey] = string(publishedBytes)
+	cm, err = k8sCli.CoreV1().ConfigMaps("test-ns").Update(ctx, cm, metav1.UpdateOptions{})
+	assert.NoError(t, err)
+	assert.NoError(t, k8sCli.CoreV1().ConfigMaps("ns1").Delete(ctx, "n1", metav1.DeleteOptions{}))
+	assert.NoError(t, a.runOnce(ctx))
+	diff = a.LastDiff()
+	assert.Equal(t, 2, diff.Count(ChangeRemoved))
+	assert.Contains(t, diff.Blocked, "ns1/test, ns3/test")
+	assert.Equal(t, float64(0), testutil.ToFloat64(metrics.PublishBlocked.WithLabelValues("test-dry-run")))
+
+	// It would not be published while it's pinned.
+	cm.Annotations = map[string]string{PinnedRevisionAnnotation: "1"}
+	_, err = k8sCli.CoreV1().ConfigMaps("test-ns").Update(ctx, cm, metav1.UpdateOptions{})
+	assert.NoError(t, err)
+	assert.NoError(t, a.runOnce(ctx))
+	diff = a.LastDiff()
+	assert.Equal(t, 1, diff.PinnedRevision)
+	assert.Empty(t, diff.Blocked)
+}
@@ -0,0 +1,83 @@
+package aggregator
+
+import (
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	ReasonInvalidConfig  = "InvalidConfig"
+	ReasonEmptyConfig    = "EmptyConfig"
+	ReasonConfigAccepted = "ConfigAccepted"
+)
+
+type sourceEvent struct {
+	eventType string
+	reason    string
+	message   string
+}
+
+// The events of an application config source according to its aggregation result.
+func (s *appSource) events() ([]sourceEvent, error) {
+	result := []sourceEvent{}
+	if len(s.keptServices) > 0 {
+		result = append(result, sourceEvent{eventType: corev1.EventTypeWarning, reason: ReasonInvalidConfig, message: fmt.Sprintf("Invalid application config, the last valid version of service(s) %s is kept in the aggregation: %s", strings.Join(s.keptServices, ", "), strings.Join(s.errors, "; "))})
+	} else if len(s.errors) > 0 {
+		result = append(result, sourceEvent{eventType: corev1.EventTypeWarning, reason: ReasonInvalidConfig, message: fmt.Sprintf("Invalid application config dropped from the aggregation: %s", strings.Join(s.errors, "; "))})
+	}
+	if len(s.emptyKeys) > 0 {
+		result = append(result, sourceEvent{eventType: corev1.EventTypeWarning, reason: ReasonEmptyConfig, message: fmt.Sprintf("Empty application config in key(s): %s", strings.Join(s.emptyKeys, ", "))})
+	}
+	if len(s.accepted) > 0 {
+		hash, err := s.hash()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, sourceEvent{eventType: corev1.EventTypeNormal, reason: ReasonConfigAccepted, message: fmt.Sprintf("Application config accepted into the aggregation, %d config(s), hash %s", len(s.accepted), hash)})
+	}
+	return result, nil
+}
+
+// Record the events of the application config sources, an event is only recorded when its message
+// changes, so that a bad config does not generate an event in every run.
+func (a *aggregator) recordSourceEvents(sources []*appSource) {
+	if a.recorder == nil {
+		return
+	}
+	seen := map[string]bool{}
+	for _, src := range sources {
+		// No object to record the events to, e.g. a local file.
+		if src.object == nil {
+			continue
+		}
+		events, err := src.events()
+		if err != nil {
+			a.logger.Errorw("Failed to generate the events of the application config", append(src.logFields(), zap.Error(err))...)
+			continue
+		}
+		for _, e := range events {
+			key := fmt.Sprintf("%s/%s/%s/%s", src.kind, src.namespace, src.name, e.reason)
+			seen[key] = true
+			last, ok := a.lastEvents[key]
+			if ok && last == e.message {
+				continue
+			}
+			a.lastEvents[key] = e.message
+			if !ok && e.reason == ReasonConfigAccepted {
+				if hash, err := src.hash(); err == nil && src.alreadyAggregated(hash) {
+					// Already accepted before the aggregator started.
+					continue
+				}
+			}
+			a.recorder.Event(src.object, e.eventType, e.reason, e.message)
+		}
+	}
+	for key := range a.lastEvents {
+		if !seen[key] {
+			delete(a.lastEvents, key)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package aggregator
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+func Test_recordSourceEvents(t *testing.T) {
+	k8sCli := k8sfake.NewSimpleClientset()
+	cm := fakeAppConfigMap(t, "ns1", "n1")
+	cm.Data["bad"] = "service: [oops"
+	cm.Data["empty"] = "{}"
+	_, _ = k8sCli.CoreV1().ConfigMaps("ns1").Create(context.Background(), cm, metav1.CreateOptions{})
+	path, err := os.Getwd()
+	assert.NoError(t, err)
+	recorder := record.NewFakeRecorder(10)
+	a := NewAggregator(k8sCli, "test-ns", "test-cm", WithSchemaFileDir(path+"/../../manifests/install/base"), WithEventRecorder(recorder))
+	drain := func() []string {
+		result := []string{}
+		for {
+			select {
+			case e := <-recorder.Events:
+				result = append(result, e)
+			default:
+				return result
+			}
+		}
+	}
+
+	assert.NoError(t, a.runOnce(context.Background()))
+	events := drain()
+	assert.Equal(t, 3, len(events))
+	assert.True(t, strings.HasPrefix(events[0], "Warning InvalidConfig"))
+	assert.True(t, strings.HasPrefix(events[1], "Warning EmptyConfig"))
+	assert.True(t, strings.HasPrefix(events[2], "Normal ConfigAccepted"))
+
+	// Deduplicated
+	assert.NoError(t, a.runOnce(context.Background()))
+	assert.Empty(t, drain())
+
+	// Fixed
+	cm, err = k8sCli.CoreV1().ConfigMaps("ns1").Get(context.Background(), "n1", metav1.GetOptions{})
+	assert.NoError(t, err)
+	delete(cm.Data, "bad")
+	_, err = k8sCli.CoreV1().ConfigMaps("ns1").Update(context.Background(), cm, metav1.UpdateOptions{})
+	assert.NoError(t, err)
+	assert.NoError(t, a.runOnce(context.Background()))
+	assert.Empty(t, drain())
+
+	// A new aggregator does not repeat the accepted event
+	a = NewAggregator(k8sCli, "test-ns", "test-cm", WithSchemaFileDir(path+"/../../manifests/install/base"), WithEventRecorder(recorder))
+	assert.NoError(t, a.runOnce(context.Background()))
+	events = drain()
+	assert.Equal(t, 1, len(events))
+	assert.True(t, strings.HasPrefix(events[0], "Warning EmptyConfig"))
+}
@@ -0,0 +1,145 @@
+package aggregator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/numaproj-labs/numalogic-config-aggregator/pkg/metrics"
+)
+
+const (
+	// DefaultMaxRemovedPercent is the default max percentage of the published configs removed in one run.
+	DefaultMaxRemovedPercent = 50
+
+	ReasonMassRemovalBlocked  = "MassRemovalBlocked"
+	ReasonUnreadablePublished = "UnreadablePublishedConfig"
+)
+
+// Check how many of the published application configs would be removed from the aggregated ConfigMap,
+// it fails if more than maxRemovedPercent percent, or more than maxRemovedConfigs of them would be removed,
+// unless the AllowMassRemovalAnnotation is on the aggregated ConfigMap, or it's pinned to a revision. The
+// annotation is only valid for one run, it's removed after the aggregated config is published. The expired
+// stale configs are not regarded as removals, and removing a single config, e.g. the only one, never
+// exceeds the percentage. An unreadable published config is regarded as nothing published.
+func (a *aggregator) checkRemovals(ctx context.Context, config GlobalConfig) (allowed bool, err error) {
+	if a.primarySink == nil || (a.maxRemovedPercent <= 0 && a.maxRemovedConfigs <= 0) {
+		return false, nil
+	}
+	if a.published == nil {
+		published, found, err := a.primarySink.read(ctx)
+		a.published = map[string]bool{}
+		if err != nil {
+			// Regarded as nothing published, e.g. a missing shard or a broken config, so that it's repaired by
+			// the new aggregated config instead of blocking every run.
+			a.logger.Errorw("Failed to read the published configs, the removals are not checked", zap.Error(err))
+			a.recordUnreadablePublished(ctx, err)
+		} else if found {
+			a.setPublished(published)
+		}
+	}
+	removed, exceeded := a.exceedsRemovalLimits(a.published, config)
+	if !exceeded {
+		metrics.PublishBlocked.WithLabelValues(a.name).Set(0)
+		return false, nil
+	}
+	// The pinned revision is published instead of the new aggregated config.
+	pinned, err := a.pinnedRevision(ctx)
+	if err != nil {
+		return false, err
+	}
+	if pinned > 0 {
+		metrics.PublishBlocked.WithLabelValues(a.name).Set(0)
+		return false, nil
+	}
+	cm, err := a.k8sclient.CoreV1().ConfigMaps(a.namespace).Get(ctx, a.configMap, metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return false, fmt.Errorf("failed to get aggregated configmap, %w", err)
+	}
+	if err == nil && cm.Annotations[AllowMassRemovalAnnotation] == "true" {
+		a.logger.Warnw("Removing the application configs as allowed by the annotation", zap.Strings("removed", removed), zap.Int("published", len(a.published)))
+		metrics.PublishBlocked.WithLabelValues(a.name).Set(0)
+		return true, nil
+	}
+	metrics.PublishBlocked.WithLabelValues(a.name).Set(1)
+	message := blockedMessage(removed, len(a.published))
+	if a.recorder != nil && err == nil && message != a.lastBlockedMessage {
+		a.recorder.Event(cm, corev1.EventTypeWarning, ReasonMassRemovalBlocked, message)
+	}
+	a.lastBlockedMessage = message
+	return false, errors.New(message)
+}
+
+// The published application configs removed from the new aggregated config, and whether they exceed the limits.
+func (a *aggregator) exceedsRemovalLimits(published map[string]bool, config GlobalConfig) ([]string, bool) {
+	current := publishedKeys(config)
+	var removed []string
+	for key := range published {
+		if !current[key] && !a.expiredStale[key] {
+			removed = append(removed, key)
+		}
+	}
+	sort.Strings(removed)
+	exceeded := (a.maxRemovedPercent > 0 && len(removed) > 1 && len(removed)*100 > a.maxRemovedPercent*len(published)) ||
+		(a.maxRemovedConfigs > 0 && len(removed) > a.maxRemovedConfigs)
+	return removed, exceeded
+}
+
+func blockedMessage(removed []string, published int) string {
+	return fmt.Sprintf("Refused to publish the aggregated config removing %d of the %d application configs: %s, annotate the ConfigMap with %s=true to allow it", len(removed), published, strings.Join(removed, ", "), AllowMassRemovalAnnotation)
+}
+
+// Record an event on the aggregated ConfigMap that the published config is unreadable.
+func (a *aggregator) recordUnreadablePublished(ctx context.Context, readErr error) {
+	if a.recorder == nil {
+		return
+	}
+	cm, err := a.k8sclient.CoreV1().ConfigMaps(a.namespace).Get(ctx, a.configMap, metav1.GetOptions{})
+	if err != nil {
+		return
+	}
+	a.recorder.Event(cm, corev1.EventTypeWarning, ReasonUnreadablePublished, fmt.Sprintf("Failed to read the published config, it's overwritten without checking the removals: %v", readErr))
+}
+
+// Record the application configs published to the aggregated ConfigMap.
+func (a *aggregator) setPublished(config GlobalConfig) {
+	a.published = publishedKeys(config)
+	a.expiredStale = nil
+	a.lastBlockedMessage = ""
+}
+
+// The keys of the application configs in the aggregated config.
+func publishedKeys(config GlobalConfig) map[string]bool {
+	keys := map[string]bool{}
+	for _, c := range config.Configs {
+		if key, ok := knownGoodKey(c); ok {
+			keys[key] = true
+		}
+	}
+	return keys
+}
+
+// Remove the AllowMassRemovalAnnotation from the aggregated ConfigMap after it's used.
+func (a *aggregator) consumeMassRemovalAllowance(ctx context.Context) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{AllowMassRemovalAnnotation: nil},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := a.k8sclient.CoreV1().ConfigMaps(a.namespace).Patch(ctx, a.configMap, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		return fmt.Errorf("failed to remove the annotation %s, %w", AllowMassRemovalAnnotation, err)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package aggregator
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/yaml"
+
+	"github.com/numaproj-labs/numalogic-config-aggregator/pkg/metrics"
+)
+
+func Test_checkRemovals(t *testing.T) {
+	ctx := context.Background()
+	path, err := os.Getwd()
+	assert.NoError(t, err)
+	k8sCli := k8sfake.NewSimpleClientset()
+	for _, ns := range []string{"ns1", "ns2", "ns3"} {
+		_, err := k8sCli.CoreV1().ConfigMaps(ns).Create(ctx, fakeAppConfigMap(t, ns, "n1"), metav1.CreateOptions{})
+		assert.NoError(t, err)
+	}
+	recorder := record.NewFakeRecorder(10)
+	a := NewAggregator(k8sCli, "test-ns", "test-cm", WithName("test-guard"), WithSchemaFileDir(path+"/../../manifests/install/base"), WithEventRecorder(recorder), WithStatusAnnotations(false))
+	assert.NoError(t, a.runOnce(ctx))
+	configs := func() int {
+		cm, err := k8sCli.CoreV1().ConfigMaps("test-ns").Get(ctx, "test-cm", metav1.GetOptions{})
+		assert.NoError(t, err)
+		var c GlobalConfig
+		assert.NoError(t, yaml.Unmarshal([]byte(cm.Data[defaultSettings.configMapKey]), &c))
+		return len(c.Configs)
+	}
+	assert.Equal(t, 3, configs())
+
+	// Removing 1 of 3 is allowed.
+	assert.NoError(t, k8sCli.CoreV1().ConfigMaps("ns1").Delete(ctx, "n1", metav1.DeleteOptions{}))
+	assert.NoError(t, a.runOnce(ctx))
+	assert.Equal(t, 2, configs())
+	assert.Equal(t, float64(0), testutil.ToFloat64(metrics.PublishBlocked.WithLabelValues("test-guard")))
+
+	// Removing 2 of 2 is blocked, the event is recorded once.
+	assert.NoError(t, k8sCli.CoreV1().ConfigMaps("ns2").Delete(ctx, "n1", metav1.DeleteOptions{}))
+	assert.NoError(t, k8sCli.CoreV1().ConfigMaps("ns3").Delete(ctx, "n1", metav1.DeleteOptions{}))
+	assert.Error(t, a.runOnce(ctx))
+	assert.Error(t, a.runOnce(ctx))
+	assert.Equal(t, 2, configs())
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.PublishBlocked.WithLabelValues("test-guard")))
+	var blocked []string
+	for len(recorder.Events) > 0 {
+		if e := <-recorder.Events; strings.Contains(e, ReasonMassRemovalBlocked) {
+			blocked = append(blocked, e)
+		}
+	}
+	assert.Len(t, blocked, 1)
+	assert.Contains(t, blocked[0], "ns2/test, ns3/test")
+
+	// A new aggregator seeds the published configs from the aggregated ConfigMap.
+	a = NewAggregator(k8sCli, "test-ns", "test-cm", WithName("test-guard"), WithSchemaFileDir(path+"/../../manifests/install/base"), WithStatusAnnotations(false))
+	assert.Error(t, a.runOnce(ctx))
+
+	// The annotation allows one run.
+	cm, err := k8sCli.CoreV1().ConfigMaps("test-ns").Get(ctx, "test-cm", metav1.GetOptions{})
+	assert.NoError(t, err)
+	cm.Annotations[AllowMassRemovalAnnotation] = "true"
+	_, err = k8sCli.CoreV1().ConfigMaps("test-ns").Update(ctx, cm, metav1.UpdateOptions{})
+	assert.NoError(t, err)
+	assert.NoError(t, a.runOnce(ctx))
+	assert.Equal(t, 0, configs())
+	assert.Equal(t, float64(0), testutil.ToFloat64(metrics.PublishBlocked.WithLabelValues("test-guard")))
+	cm, err = k8sCli.CoreV1().ConfigMaps("test-ns").Get(ctx, "test-cm", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.NotContains(t, cm.Annotations, AllowMassRemovalAnnotation)
+
+	// The new aggregated config is not published while it's pinned.
+	a = NewAggregator(k8sCli, "test-ns", "test-cm", WithSchemaFileDir(path+"/../../manifests/install/base"))
+	a.published = map[string]bool{"ns1/a": true, "ns1/b": true}
+	_, err = a.checkRemovals(ctx, GlobalConfig{})
+	assert.Error(t, err)
+	cm, err = k8sCli.CoreV1().ConfigMaps("test-ns").Get(ctx, "test-cm", metav1.GetOptions{})
+	assert.NoError(t, err)
+	cm.Annotations[PinnedRevisionAnnotation] = "1"
+	_, err = k8sCli.CoreV1().ConfigMaps("test-ns").Update(ctx, cm, metav1.UpdateOptions{})
+	assert.NoError(t, err)
+	_, err = a.checkRemovals(ctx, GlobalConfig{})
+	assert.NoError(t, err)
+	assert.NoError(t, UnpinRevision(ctx, k8sCli, "test-ns", "test-cm"))
+
+	// The limit on the number.
+	a = NewAggregator(k8sCli, "test-ns", "test-cm", WithSchemaFileDir(path+"/../../manifests/install/base"), WithMaxRemovals(0, 1))
+	a.published = map[string]bool{"ns1/a": true, "ns1/b": true}
+	_, err = a.checkRemovals(ctx, GlobalConfig{})
+	assert.Error(t, err)
+	_, err = a.checkRemovals(ctx, GlobalConfig{Configs: []obj{{Namespace: "ns1", "service": "a"}}})
+	assert.NoError(t, err)
+
+	// The expired stale configs are not removals, and removing a single config never exceeds the percentage.
+	a = NewAggregator(k8sCli, "test-ns", "test-cm", WithSchemaFileDir(path+"/../../manifests/install/base"))
+	a.published = map[string]bool{"ns1/a": true, "ns1/b": true, "ns1/c": true}
+	a.expiredStale = map[string]bool{"ns1/a": true, "ns1/b": true}
+	_, err = a.checkRemovals(ctx, GlobalConfig{})
+	assert.NoError(t, err)
+	a.published = map[string]bool{"ns1/a": true}
+	a.expiredStale = nil
+	_, err = a.checkRemovals(ctx, GlobalConfig{})
+	assert.NoError(t, err)
+}
+
+func Test_checkRemovals_unreadablePublished(t *testing.T) {
+	ctx := context.Background()
+	path, err := os.Getwd()
+	assert.NoError(t, err)
+	k8sCli := k8sfake.NewSimpleClientset(&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "test-cm"}, Data: map[string]string{defaultSettings.configMapKey: "configs: [oops"}})
+	_, err = k8sCli.CoreV1().ConfigMaps("ns1").Create(ctx, fakeAppConfigMap(t, "ns1", "n1"), metav1.CreateOptions{})
+	assert.NoError(t, err)
+	recorder := record.NewFakeRecorder(10)
+	a := NewAggregator(k8sCli, "test-ns", "test-cm", WithSchemaFileDir(path+"/../../manifests/install/base"), WithEventRecorder(recorder), WithStatusAnnotations(false))
+
+	// The broken aggregated config is repaired.
+	assert.NoError(t, a.runOnce(ctx))
+	cm, err := k8sCli.CoreV1().ConfigMaps("test-ns").Get(ctx, "test-cm", metav1.GetOptions{})
+	assert.NoError(t, err)
+	var c GlobalConfig
+	assert.NoError(t, yaml.Unmarshal([]byte(cm.Data[defaultSettings.configMapKey]), &c))
+	assert.Len(t, c.Configs, 1)
+	var unreadable []string
+	for len(recorder.Events) > 0 {
+		if e := <-recorder.Events; strings.Contains(e, ReasonUnreadablePublished) {
+			unreadable = append(unreadable, e)
+		}
+	}
+	assert.Len(t, unreadable, 1)
+}
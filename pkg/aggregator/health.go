@@ -0,0 +1,89 @@
+package aggregator
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// The state of the run loop, used for the health checks.
+type runState struct {
+	lock sync.RWMutex
+	// Whether the run loop is started, i.e. it's the leader, and when it started
+	running   bool
+	startedAt time.Time
+	// Whether a run is in progress, and when it started
+	inProgress   bool
+	runStartedAt time.Time
+	// Whether any run succeeded
+	succeeded bool
+}
+
+func (s *runState) start() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.running = true
+	s.startedAt = time.Now()
+}
+
+func (s *runState) stop() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.running = false
+	s.inProgress = false
+}
+
+func (s *runState) runStarted(t time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.inProgress = true
+	s.runStartedAt = t
+}
+
+func (s *runState) runFinished(succeeded bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.inProgress = false
+	s.succeeded = s.succeeded || succeeded
+}
+
+// Ready returns an error if the schema is not loaded, or the run loop is started but
+// there's no successful run yet.
+func (a *aggregator) Ready() error {
+	a.schemaLock.RLock()
+	schemaErr := a.schemaErr
+	a.schemaLock.RUnlock()
+	if schemaErr != nil {
+		return fmt.Errorf("aggregation %q failed to load the schema, %w", a.name, schemaErr)
+	}
+	a.state.lock.RLock()
+	defer a.state.lock.RUnlock()
+	if a.state.running && !a.state.succeeded {
+		return fmt.Errorf("aggregation %q has no successful run yet", a.name)
+	}
+	return nil
+}
+
+// Healthy returns an error if the run loop is wedged, i.e. a run takes longer than the timeout, or no run
+// starts within the interval plus the timeout, e.g. the loop is stuck before its first run.
+func (a *aggregator) Healthy() error {
+	a.state.lock.RLock()
+	defer a.state.lock.RUnlock()
+	if !a.state.running {
+		return nil
+	}
+	if a.state.inProgress {
+		if time.Since(a.state.runStartedAt) > a.runTimeout {
+			return fmt.Errorf("aggregation %q has a run in progress since %s", a.name, a.state.runStartedAt.Format(time.RFC3339))
+		}
+		return nil
+	}
+	lastStarted := a.state.runStartedAt
+	if lastStarted.Before(a.state.startedAt) {
+		lastStarted = a.state.startedAt
+	}
+	if time.Since(lastStarted) > a.interval+a.runTimeout {
+		return fmt.Errorf("aggregation %q has no run started since %s", a.name, lastStarted.Format(time.RFC3339))
+	}
+	return nil
+}
@@ -0,0 +1,20 @@
+package aggregator
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+type Aggregator interface {
+	Run(context.Context)
+	// Ready returns an error if the aggregator is not ready.
+	Ready() error
+	// Healthy returns an error if the aggregator is not healthy.
+	Healthy() error
+	// ValidateConfigMap returns whether the ConfigMap is an application config source of the aggregator,
+	// and the errors of its invalid entries.
+	ValidateConfigMap(context.Context, *corev1.ConfigMap) (bool, []string)
+	// LastDiff returns the difference computed by the last dry run, nil if there's none.
+	LastDiff() *ConfigDiff
+}
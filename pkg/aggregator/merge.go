@@ -0,0 +1,250 @@
+package aggregator
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+type MergeStrategy string
+
+const (
+	// MergeStrategyNone emits one entry for each key of the application config source.
+	MergeStrategyNone MergeStrategy = "none"
+	// MergeStrategyConcat merges all the keys of the application config source into one entry,
+	// lists such as metric_configs and unified_configs are concatenated, other fields
+	// (e.g. service) are required to be identical.
+	MergeStrategyConcat MergeStrategy = "concat"
+)
+
+// Validate and convert all the entries in an application config source, invalid and empty entries are skipped
+// and recorded in the source.
+func (a *aggregator) convertSource(src *appSource) []obj {
+	keys := make([]string, 0, len(src.data))
+	for key := range src.data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	result := []obj{}
+	for _, key := range keys { // Iterate all the key/value pairs in the source
+		appConfig, err := a.convert(src.data[key])
+		if err != nil {
+			a.logger.Errorw("Invalid application config", append(src.logFields(), zap.String("key", key), zap.Error(err))...)
+			src.errors = append(src.errors, fmt.Sprintf("%s: %v", key, err))
+			continue
+		}
+		if len(appConfig) == 0 {
+			a.logger.Warnw("Empty application config", append(src.logFields(), zap.String("key", key))...)
+			src.emptyKeys = append(src.emptyKeys, key)
+			continue
+		}
+		result = append(result, appConfig)
+	}
+	strategy := src.mergeStrategy()
+	switch strategy {
+	case MergeStrategyNone:
+		return result
+	case MergeStrategyConcat:
+		if len(result) == 0 {
+			return result
+		}
+		merged := obj{}
+		for _, appConfig := range result {
+			if err := mergeAppConfig(merged, appConfig); err != nil {
+				a.logger.Errorw("Failed to merge application config", append(src.logFields(), zap.Error(err))...)
+				src.errors = append(src.errors, fmt.Sprintf("failed to merge entries, %v", err))
+				return []obj{}
+			}
+		}
+		return []obj{merged}
+	default:
+		a.logger.Errorw("Unknown merge strategy", append(src.logFields(), zap.String("strategy", string(strategy)))...)
+		src.errors = append(src.errors, fmt.Sprintf("unknown merge strategy %q", strategy))
+		return []obj{}
+	}
+}
+
+func (s *appSource) mergeStrategy() MergeStrategy {
+	ms, ok := s.annotations[MergeStrategyAnnotation]
+	if !ok || strings.TrimSpace(ms) == "" {
+		return MergeStrategyNone
+	}
+	return MergeStrategy(strings.ToLower(strings.TrimSpace(ms)))
+}
+
+// Merge the src application config into dst, lists are concatenated,
+// other values are required to be equal.
+func mergeAppConfig(dst, src obj) error {
+	for k, v := range src {
+		existing, ok := dst[k]
+		if !ok {
+			dst[k] = v
+			continue
+		}
+		l1, ok1 := existing.([]interface{})
+		l2, ok2 := v.([]interface{})
+		if ok1 && ok2 {
+			merged := make([]interface{}, 0, len(l1)+len(l2))
+			merged = append(merged, l1...)
+			dst[k] = append(merged, l2...)
+			continue
+		}
+		if !reflect.DeepEqual(existing, v) {
+			return fmt.Errorf("conflicting values of %q: %v and %v", k, existing, v)
+		}
+	}
+	return nil
+}
+
+// The identity field of the items in the lists of an application config,
+// items with the same identity from different sources are considered the same item.
+var listItemIdentities = map[string]string{
+	"metric_configs":  "metric",
+	"unified_configs": "unified_metric_name",
+}
+
+// Sort the sources by namespace, then by precedence in each namespace, which is
+// the priority annotation in descending order, and then the kind and the name.
+func sortByPrecedence(sources []*appSource) {
+	sort.SliceStable(sources, func(i, j int) bool {
+		if sources[i].namespace != sources[j].namespace {
+			return sources[i].namespace < sources[j].namespace
+		}
+		pi, pj := sources[i].priority(), sources[j].priority()
+		if pi != pj {
+			return pi > pj
+		}
+		if sources[i].kind != sources[j].kind {
+			return sources[i].kind < sources[j].kind
+		}
+		return sources[i].name < sources[j].name
+	})
+}
+
+func (s *appSource) priority() int {
+	p, err := strconv.Atoi(strings.TrimSpace(s.annotations[PriorityAnnotation]))
+	if err != nil {
+		return 0
+	}
+	return p
+}
+
+// Merge the configs from the sources in one namespace into one config per service,
+// the sources are expected to be sorted by precedence.
+func (a *aggregator) mergeNamespaceConfigs(sources []*appSource) []obj {
+	services := []string{}
+	merged := map[string]*serviceConfig{}
+	for _, src := range sources {
+		for _, appConfig := range a.convertSource(src) {
+			service, _ := appConfig["service"].(string)
+			sc, ok := merged[service]
+			if !ok {
+				sc = &serviceConfig{config: obj{}, owners: map[string]string{}}
+				merged[service] = sc
+				services = append(services, service)
+			}
+			if err := sc.merge(src.String(), appConfig); err != nil {
+				a.logger.Errorw("Conflicting application config", append(src.logFields(), zap.String("service", service), zap.Error(err))...)
+				src.errors = append(src.errors, err.Error())
+				continue
+			}
+			src.accepted = append(src.accepted, appConfig)
+		}
+	}
+	result := make([]obj, 0, len(services))
+	for _, s := range services {
+		result = append(result, merged[s].config)
+	}
+	return result
+}
+
+// The merged config of a service from multiple sources.
+type serviceConfig struct {
+	config obj
+	// The source of each identified list item, keyed by "<list>/<identity>".
+	owners map[string]string
+}
+
+// Merge an application config from a source, lists are merged by the identity of the items,
+// other fields keep the values from the source with higher precedence.
+// Nothing is merged if there's any conflict.
+func (sc *serviceConfig) merge(source string, src obj) error {
+	for k, v := range src {
+		idField, ok := listItemIdentities[k]
+		if !ok {
+			continue
+		}
+		items, _ := v.([]interface{})
+		seen := map[string]interface{}{}
+		for _, item := range items {
+			id, ok := itemIdentity(item, idField)
+			if !ok {
+				continue
+			}
+			if prev, ok := seen[id]; ok && !reflect.DeepEqual(prev, item) {
+				return fmt.Errorf("%s %q is defined more than once with different settings in %s", idField, id, source)
+			}
+			seen[id] = item
+			owner, ok := sc.owners[k+"/"+id]
+			if !ok {
+				continue
+			}
+			if !reflect.DeepEqual(findItem(sc.config[k], idField, id), item) {
+				return fmt.Errorf("%s %q in %s conflicts with the one in %s", idField, id, source, owner)
+			}
+		}
+	}
+	for k, v := range src {
+		existing, ok := sc.config[k]
+		if !ok {
+			existing = []interface{}{}
+		}
+		l1, ok1 := existing.([]interface{})
+		l2, ok2 := v.([]interface{})
+		if !ok1 || !ok2 {
+			if _, ok := sc.config[k]; !ok {
+				sc.config[k] = v
+			}
+			continue
+		}
+		idField, identified := listItemIdentities[k]
+		merged := make([]interface{}, 0, len(l1)+len(l2))
+		merged = append(merged, l1...)
+		for _, item := range l2 {
+			if identified {
+				if id, ok := itemIdentity(item, idField); ok {
+					if _, ok := sc.owners[k+"/"+id]; ok {
+						continue
+					}
+					sc.owners[k+"/"+id] = source
+				}
+			}
+			merged = append(merged, item)
+		}
+		sc.config[k] = merged
+	}
+	return nil
+}
+
+func itemIdentity(item interface{}, idField string) (string, bool) {
+	o, ok := item.(obj)
+	if !ok {
+		return "", false
+	}
+	id, ok := o[idField].(string)
+	return id, ok
+}
+
+func findItem(list interface{}, idField, id string) interface{} {
+	items, _ := list.([]interface{})
+	for _, item := range items {
+		if i, ok := itemIdentity(item, idField); ok && i == id {
+			return item
+		}
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package aggregator
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+var (
+	splitConfigStr1 = `service: test
+metric_configs:
+- metric: m1
+  composite_keys: [ck11, ck12]
+unified_configs:
+- unified_metric_name: umn1
+  unified_metrics: [m1]
+`
+	splitConfigStr2 = `service: test
+metric_configs:
+- metric: m2
+  composite_keys: [ck21, ck22]
+unified_configs: []
+`
+	conflictConfigStr = `service: another
+metric_configs:
+- metric: m3
+unified_configs: []
+`
+)
+
+func Test_convertConfigMap(t *testing.T) {
+	path, err := os.Getwd()
+	assert.NoError(t, err)
+	a := NewAggregator(k8sfake.NewSimpleClientset(), "ns", "cm", WithSchemaFileDir(path+"/../../manifests/install/base"))
+
+	t.Run("none", func(t *testing.T) {
+		cm := fakeAppConfigMap(t, "ns1", "n1")
+		cm.Data = map[string]string{"a": splitConfigStr1, "b": splitConfigStr2}
+		configs := a.convertSource(newConfigMapSource(cm))
+		assert.Equal(t, 2, len(configs))
+	})
+
+	t.Run("concat", func(t *testing.T) {
+		cm := fakeAppConfigMap(t, "ns1", "n1")
+		cm.Annotations = map[string]string{MergeStrategyAnnotation: "concat"}
+		cm.Data = map[string]string{"b": splitConfigStr2, "a": splitConfigStr1, "c": "invalid"}
+		src := newConfigMapSource(cm)
+		configs := a.convertSource(src)
+		assert.Equal(t, 1, len(src.errors))
+		assert.Equal(t, 1, len(configs))
+		assert.Equal(t, "test", configs[0]["service"])
+		mc, ok := configs[0]["metric_configs"].([]interface{})
+		assert.True(t, ok)
+		assert.Equal(t, 2, len(mc))
+		assert.Equal(t, "m1", mc[0].(obj)["metric"])
+		assert.Equal(t, "m2", mc[1].(obj)["metric"])
+		uc, ok := configs[0]["unified_configs"].([]interface{})
+		assert.True(t, ok)
+		assert.Equal(t, 1, len(uc))
+	})
+
+	t.Run("conflict", func(t *testing.T) {
+		cm := fakeAppConfigMap(t, "ns1", "n1")
+		cm.Annotations = map[string]string{MergeStrategyAnnotation: "concat"}
+		cm.Data = map[string]string{"a": splitConfigStr1, "b": conflictConfigStr}
+		assert.Equal(t, 0, len(a.convertSource(newConfigMapSource(cm))))
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		cm := fakeAppConfigMap(t, "ns1", "n1")
+		cm.Annotations = map[string]string{MergeStrategyAnnotation: "whatever"}
+		assert.Equal(t, 0, len(a.convertSource(newConfigMapSource(cm))))
+	})
+}
+
+func Test_mergeAppConfig(t *testing.T) {
+	dst := obj{}
+	assert.NoError(t, mergeAppConfig(dst, obj{"service": "s", "metric_configs": []interface{}{"a"}}))
+	assert.NoError(t, mergeAppConfig(dst, obj{"service": "s", "metric_configs": []interface{}{"b"}}))
+	assert.Equal(t, []interface{}{"a", "b"}, dst["metric_configs"])
+	assert.Error(t, mergeAppConfig(dst, obj{"service": "t"}))
+}
+
+func Test_sortByPrecedence(t *testing.T) {
+	cms := []*corev1.ConfigMap{
+		fakeAppConfigMap(t, "ns2", "a"),
+		fakeAppConfigMap(t, "ns1", "b"),
+		fakeAppConfigMap(t, "ns1", "c"),
+		fakeAppConfigMap(t, "ns1", "a"),
+	}
+	cms[2].Annotations = map[string]string{PriorityAnnotation: "10"}
+	sources := []*appSource{}
+	for _, cm := range cms {
+		sources = append(sources, newConfigMapSource(cm))
+	}
+	sortByPrecedence(sources)
+	names := []string{}
+	for _, src := range sources {
+		names = append(names, src.namespace+"/"+src.name)
+	}
+	assert.Equal(t, []string{"ns1/c", "ns1/a", "ns1/b", "ns2/a"}, names)
+}
+
+func Test_mergeNamespaceConfigs(t *testing.T) {
+	path, err := os.Getwd()
+	assert.NoError(t, err)
+	a := NewAggregator(k8sfake.NewSimpleClientset(), "ns", "cm", WithSchemaFileDir(path+"/../../manifests/install/base"), WithNamespaceMerge(true))
+
+	t.Run("merge", func(t *testing.T) {
+		cm1 := fakeAppConfigMap(t, "ns1", "a")
+		cm1.Data = map[string]string{"a": splitConfigStr1}
+		cm2 := fakeAppConfigMap(t, "ns1", "b")
+		cm2.Data = map[string]string{"b": splitConfigStr2, "c": splitConfigStr1}
+		cm3 := fakeAppConfigMap(t, "ns1", "c")
+		cm3.Data = map[string]string{"a": conflictConfigStr}
+		sources := []*appSource{newConfigMapSource(cm1), newConfigMapSource(cm2), newConfigMapSource(cm3)}
+		configs := a.mergeNamespaceConfigs(sources)
+		assert.Equal(t, 2, len(configs))
+		assert.Equal(t, "test", configs[0]["service"])
+		mc, ok := configs[0]["metric_configs"].([]interface{})
+		assert.True(t, ok)
+		assert.Equal(t, 2, len(mc))
+		uc, ok := configs[0]["unified_configs"].([]interface{})
+		assert.True(t, ok)
+		assert.Equal(t, 1, len(uc))
+		assert.Equal(t, "another", configs[1]["service"])
+		for _, src := range sources {
+			assert.Empty(t, src.errors)
+		}
+	})
+
+	t.Run("conflict", func(t *testing.T) {
+		cm1 := fakeAppConfigMap(t, "ns1", "a")
+		cm1.Data = map[string]string{"a": splitConfigStr1}
+		cm2 := fakeAppConfigMap(t, "ns1", "b")
+		cm2.Data = map[string]string{"a": strings.Replace(splitConfigStr2, "metric: m2", "metric: m1", 1)}
+		sources := []*appSource{newConfigMapSource(cm1), newConfigMapSource(cm2)}
+		configs := a.mergeNamespaceConfigs(sources)
+		assert.Equal(t, 1, len(configs))
+		mc, ok := configs[0]["metric_configs"].([]interface{})
+		assert.True(t, ok)
+		assert.Equal(t, 1, len(mc))
+		assert.Equal(t, []interface{}{"ck11", "ck12"}, mc[0].(obj)["composite_keys"])
+		assert.Empty(t, sources[0].errors)
+		assert.Equal(t, 1, len(sources[1].errors))
+		assert.Empty(t, sources[1].accepted)
+	})
+}
+
+func Test_serviceConfig_merge(t *testing.T) {
+	sc := &serviceConfig{config: obj{}, owners: map[string]string{}}
+	m1 := obj{"metric": "m1", "static_threshold": 1}
+	assert.NoError(t, sc.merge(`ConfigMap "a"`, obj{"service": "s", "metric_configs": []interface{}{m1}}))
+	assert.NoError(t, sc.merge(`ConfigMap "b"`, obj{"service": "s", "metric_configs": []interface{}{m1, obj{"metric": "m2"}}}))
+	assert.Equal(t, 2, len(sc.config["metric_configs"].([]interface{})))
+	err := sc.merge(`ConfigMap "c"`, obj{"metric_configs": []interface{}{obj{"metric": "m1", "static_threshold": 2}}})
+	assert.EqualError(t, err, `metric "m1" in ConfigMap "c" conflicts with the one in ConfigMap "a"`)
+	assert.Equal(t, 2, len(sc.config["metric_configs"].([]interface{})))
+}
@@ -0,0 +1,135 @@
+package aggregator
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/informers"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Whether the application configs in the namespace are allowed by the include list and the exclude regex,
+// the namespace label selector is not checked.
+func (a *aggregator) namespaceIncluded(ns string) bool {
+	if len(a.includeNamespaces) > 0 && !sets.NewString(a.includeNamespaces...).Has(ns) {
+		return false
+	}
+	if a.excludeNamespaces != nil && a.excludeNamespaces.MatchString(ns) {
+		return false
+	}
+	return true
+}
+
+// Whether the namespace matches the namespace label selector, from the informer cache if it's available.
+func (a *aggregator) namespaceSelected(ctx context.Context, ns string) (bool, error) {
+	if a.namespaceSelector == "" || a.k8sclient == nil {
+		return true, nil
+	}
+	selector, err := labels.Parse(a.namespaceSelector)
+	if err != nil {
+		return false, fmt.Errorf("invalid namespace selector %q, %w", a.namespaceSelector, err)
+	}
+	if nsLister := a.getNamespaceLister(); nsLister != nil {
+		// The lister only has the namespaces matching the selector.
+		if _, err := nsLister.Get(ns); err == nil {
+			return true, nil
+		}
+	}
+	namespace, err := a.k8sclient.CoreV1().Namespaces().Get(ctx, ns, metav1.GetOptions{})
+	if err != nil {
+		return false, fmt.Errorf("failed to get namespace %s, %w", ns, err)
+	}
+	return selector.Matches(labels.Set(namespace.Labels)), nil
+}
+
+// The namespaces to list and watch the application configs in, all namespaces unless it's namespaced.
+func (a *aggregator) watchedNamespaces() []string {
+	if a.namespaced {
+		return a.includeNamespaces
+	}
+	return []string{metav1.NamespaceAll}
+}
+
+// Filter out the sources in the namespaces which are not allowed.
+func (a *aggregator) filterSources(ctx context.Context, sources []*appSource) ([]*appSource, error) {
+	var selected sets.String
+	if a.namespaceSelector != "" {
+		namespaces, err := a.listSelectedNamespaces(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list namespaces, %w", err)
+		}
+		selected = sets.NewString(namespaces...)
+	}
+	result := []*appSource{}
+	for _, src := range sources {
+		if !a.namespaceIncluded(src.namespace) || (selected != nil && !selected.Has(src.namespace)) {
+			continue
+		}
+		result = append(result, src)
+	}
+	return result, nil
+}
+
+// List the names of the namespaces matching the namespace label selector, from the informer cache if it's available.
+func (a *aggregator) listSelectedNamespaces(ctx context.Context) ([]string, error) {
+	var result []string
+	if nsLister := a.getNamespaceLister(); nsLister != nil {
+		selector, err := labels.Parse(a.namespaceSelector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid namespace selector %q, %w", a.namespaceSelector, err)
+		}
+		nss, err := nsLister.List(selector)
+		if err != nil {
+			return nil, err
+		}
+		for _, ns := range nss {
+			result = append(result, ns.Name)
+		}
+		return result, nil
+	}
+	nsList, err := a.k8sclient.CoreV1().Namespaces().List(ctx, metav1.ListOptions{LabelSelector: a.namespaceSelector})
+	if err != nil {
+		return nil, err
+	}
+	for _, ns := range nsList.Items {
+		result = append(result, ns.Name)
+	}
+	return result, nil
+}
+
+// Watch the namespaces matching the namespace label selector, a namespace starting or stopping to match
+// the selector triggers an aggregation.
+func (a *aggregator) startNamespaceInformer(ctx context.Context, notify func()) error {
+	factory := informers.NewSharedInformerFactoryWithOptions(a.k8sclient, 0, informers.WithTweakListOptions(func(o *metav1.ListOptions) {
+		o.LabelSelector = a.namespaceSelector
+	}))
+	nsInformer := factory.Core().V1().Namespaces()
+	_, err := nsInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			notify()
+		},
+		DeleteFunc: func(obj interface{}) {
+			notify()
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add namespace event handler, %w", err)
+	}
+	if failed := a.startAndSync(ctx, []namespacedInformer{{start: factory.Start, synced: nsInformer.Informer().HasSynced}}); len(failed) > 0 {
+		return fmt.Errorf("failed to sync informer cache of the namespaces within %s", a.syncTimeout)
+	}
+	a.nsListerLock.Lock()
+	defer a.nsListerLock.Unlock()
+	a.nsLister = nsInformer.Lister()
+	return nil
+}
+
+func (a *aggregator) getNamespaceLister() corelisters.NamespaceLister {
+	a.nsListerLock.RLock()
+	defer a.nsListerLock.RUnlock()
+	return a.nsLister
+}
@@ -0,0 +1,104 @@
+package aggregator
+
+import (
+	"context"
+	"os"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/yaml"
+)
+
+func Test_listSources_namespaceFilters(t *testing.T) {
+	k8sCli := k8sfake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns1", Labels: map[string]string{"env": "prod"}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns2", Labels: map[string]string{"env": "prod"}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "sandbox-1"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system"}},
+	)
+	for _, ns := range []string{"ns1", "ns2", "sandbox-1", "kube-system"} {
+		_, err := k8sCli.CoreV1().ConfigMaps(ns).Create(context.Background(), fakeAppConfigMap(t, ns, "n1"), metav1.CreateOptions{})
+		assert.NoError(t, err)
+	}
+	path, err := os.Getwd()
+	assert.NoError(t, err)
+	namespaces := func(opts ...Option) []string {
+		a := NewAggregator(k8sCli, "test-ns", "test-cm", append(opts, WithSchemaFileDir(path+"/../../manifests/install/base"))...)
+		sources, err := a.listSources(context.Background())
+		assert.NoError(t, err)
+		var result []string
+		for _, src := range sources {
+			result = append(result, src.namespace)
+		}
+		return result
+	}
+
+	assert.ElementsMatch(t, []string{"ns1", "ns2", "sandbox-1", "kube-system"}, namespaces())
+	assert.ElementsMatch(t, []string{"ns1", "ns2"}, namespaces(WithNamespaceSelector("env=prod")))
+	assert.ElementsMatch(t, []string{"ns2", "sandbox-1"}, namespaces(WithIncludeNamespaces([]string{"ns2", "sandbox-1"})))
+	assert.ElementsMatch(t, []string{"ns1", "ns2"}, namespaces(WithExcludeNamespaces(regexp.MustCompile(`^(sandbox-.*|kube-.*)$`))))
+	assert.ElementsMatch(t, []string{"ns2"}, namespaces(WithNamespaceSelector("env=prod"), WithExcludeNamespaces(regexp.MustCompile(`1$`))))
+}
+
+func Test_Run_namespaced(t *testing.T) {
+	k8sCli := k8sfake.NewSimpleClientset()
+	for _, ns := range []string{"ns1", "ns2", "ns3"} {
+		_, err := k8sCli.CoreV1().ConfigMaps(ns).Create(context.Background(), fakeAppConfigMap(t, ns, "n1"), metav1.CreateOptions{})
+		assert.NoError(t, err)
+	}
+	path, err := os.Getwd()
+	assert.NoError(t, err)
+	a := NewAggregator(k8sCli, "test-ns", "test-cm", WithSchemaFileDir(path+"/../../manifests/install/base"), WithIncludeNamespaces([]string{"ns1", "ns3"}), WithNamespaced(true), WithDebounce(10*time.Millisecond))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go a.Run(ctx)
+	assert.Eventually(t, func() bool {
+		configMap, err := k8sCli.CoreV1().ConfigMaps("test-ns").Get(ctx, "test-cm", metav1.GetOptions{})
+		if err != nil {
+			return false
+		}
+		var c GlobalConfig
+		_ = yaml.Unmarshal([]byte(configMap.Data[defaultSettings.configMapKey]), &c)
+		return len(c.Configs) == 2 && c.Configs[0][Namespace] == "ns1" && c.Configs[1][Namespace] == "ns3"
+	}, 5*time.Second, 50*time.Millisecond)
+	cancel()
+	for _, action := range k8sCli.Actions() {
+		if action.GetResource().Resource == "configmaps" && (action.GetVerb() == "list" || action.GetVerb() == "watch") {
+			assert.NotEmpty(t, action.GetNamespace())
+		}
+	}
+}
+
+func Test_startInformer_namespacedForbidden(t *testing.T) {
+	k8sCli := k8sfake.NewSimpleClientset(fakeAppConfigMap(t, "ns1", "n1"), fakeAppConfigMap(t, "ns2", "n1"))
+	k8sCli.PrependReactor("list", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetNamespace() == "ns2" {
+			return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: "configmaps"}, "", nil)
+		}
+		return false, nil, nil
+	})
+	path, err := os.Getwd()
+	assert.NoError(t, err)
+	a := NewAggregator(k8sCli, "test-ns", "test-cm", WithSchemaFileDir(path+"/../../manifests/install/base"), WithIncludeNamespaces([]string{"ns1", "ns2"}), WithNamespaced(true))
+	a.syncTimeout = 100 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	err = a.startInformer(ctx, make(chan struct{}, 1))
+	assert.ErrorContains(t, err, "ns2")
+	assert.NotContains(t, err.Error(), "ns1")
+	// The synced namespaces are not watched either, so ns2 is never dropped silently.
+	assert.Empty(t, a.cmListers)
+	err = a.runOnce(ctx)
+	assert.ErrorContains(t, err, "ns2")
+	_, err = k8sCli.CoreV1().ConfigMaps("test-ns").Get(ctx, "test-cm", metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err))
+}
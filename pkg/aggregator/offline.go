@@ -0,0 +1,32 @@
+package aggregator
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// AggregateConfigMaps aggregates the configs from the given ConfigMaps matching the app config label,
+// in the same way as a run, without touching a cluster. It returns the aggregated config, and the errors
+// of the invalid sources.
+func (a *aggregator) AggregateConfigMaps(cms []corev1.ConfigMap) (GlobalConfig, []string, error) {
+	selector, err := labels.Parse(a.appConfigLabel)
+	if err != nil {
+		return GlobalConfig{}, nil, fmt.Errorf("invalid app config label %q, %w", a.appConfigLabel, err)
+	}
+	sources := []*appSource{}
+	for i := range cms {
+		if selector.Matches(labels.Set(cms[i].Labels)) && a.namespaceIncluded(cms[i].Namespace) {
+			sources = append(sources, newConfigMapSource(&cms[i]))
+		}
+	}
+	config := a.aggregate(sources)
+	var errs []string
+	for _, src := range sources {
+		for _, e := range src.errors {
+			errs = append(errs, fmt.Sprintf("%s %s/%s: %s", src.kind, src.namespace, src.name, e))
+		}
+	}
+	return config, errs, nil
+}
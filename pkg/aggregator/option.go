@@ -1,9 +1,12 @@
 package aggregator
 
 import (
+	"regexp"
 	"time"
 
 	"go.uber.org/zap"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/record"
 )
 
 type Option func(*aggregator)
@@ -42,3 +45,155 @@ func WithSchemaFileDir(p string) Option {
 		o.schemaFileDir = p
 	}
 }
+
+// WithSchemaFile sets the path of the json-schema file for validation, it overrides the dir.
+func WithSchemaFile(f string) Option {
+	return func(o *aggregator) {
+		o.schemaFile = f
+	}
+}
+
+// WithWatch sets whether to watch the application ConfigMaps and aggregate on changes.
+func WithWatch(w bool) Option {
+	return func(o *aggregator) {
+		o.watch = w
+	}
+}
+
+// WithDebounce sets the time to wait for more changes before an event triggered aggregation.
+func WithDebounce(d time.Duration) Option {
+	return func(o *aggregator) {
+		o.debounce = d
+	}
+}
+
+// WithNamespaceMerge sets whether to merge the configs from multiple ConfigMaps in one namespace.
+func WithNamespaceMerge(m bool) Option {
+	return func(o *aggregator) {
+		o.mergeNamespace = m
+	}
+}
+
+// WithStatusAnnotations sets whether to annotate the application ConfigMaps with the aggregation result.
+func WithStatusAnnotations(s bool) Option {
+	return func(o *aggregator) {
+		o.statusAnnotations = s
+	}
+}
+
+// WithEventRecorder sets the recorder of the events on the application ConfigMaps.
+func WithEventRecorder(r record.EventRecorder) Option {
+	return func(o *aggregator) {
+		o.recorder = r
+	}
+}
+
+// WithConfigMapSource sets whether to read the application configs from the labeled ConfigMaps.
+func WithConfigMapSource(c bool) Option {
+	return func(o *aggregator) {
+		o.configMapSource = c
+	}
+}
+
+// WithAppConfigResourceSource sets the client to read the application configs from the NumalogicAppConfig resources.
+func WithAppConfigResourceSource(d dynamic.Interface) Option {
+	return func(o *aggregator) {
+		o.dynamicClient = d
+	}
+}
+
+// WithName sets the name of the aggregation, which is used in the logs and metrics.
+func WithName(n string) Option {
+	return func(o *aggregator) {
+		o.name = n
+	}
+}
+
+// WithRunTimeout sets the max duration of a run before the aggregator is considered unhealthy.
+func WithRunTimeout(t time.Duration) Option {
+	return func(o *aggregator) {
+		o.runTimeout = t
+	}
+}
+
+// WithNamespaceSelector sets the label selector of the namespaces to read the application configs from.
+func WithNamespaceSelector(s string) Option {
+	return func(o *aggregator) {
+		o.namespaceSelector = s
+	}
+}
+
+// WithIncludeNamespaces sets the namespaces to read the application configs from.
+func WithIncludeNamespaces(namespaces []string) Option {
+	return func(o *aggregator) {
+		o.includeNamespaces = namespaces
+	}
+}
+
+// WithExcludeNamespaces sets the regex of the namespaces not to read the application configs from.
+func WithExcludeNamespaces(r *regexp.Regexp) Option {
+	return func(o *aggregator) {
+		o.excludeNamespaces = r
+	}
+}
+
+// WithNamespaced sets whether to list and watch the application configs in each of the included namespaces,
+// instead of cluster wide.
+func WithNamespaced(n bool) Option {
+	return func(o *aggregator) {
+		o.namespaced = n
+	}
+}
+
+// WithSinks adds the sinks of the aggregated config, besides the aggregated ConfigMap.
+func WithSinks(sinks ...Sink) Option {
+	return func(o *aggregator) {
+		o.sinks = append(o.sinks, sinks...)
+	}
+}
+
+// WithMaxConfigMapSize sets the max size of the aggregated config in one ConfigMap, it's sharded if it's larger.
+func WithMaxConfigMapSize(size int) Option {
+	return func(o *aggregator) {
+		o.maxConfigMapSize = size
+	}
+}
+
+// WithRevisionHistoryLimit sets the number of the revisions of the aggregated config to keep, 0 disables the revisions.
+func WithRevisionHistoryLimit(limit int) Option {
+	return func(o *aggregator) {
+		o.revisionHistoryLimit = limit
+	}
+}
+
+// WithSources adds the sources of the application configs, besides the labeled ConfigMaps and the NumalogicAppConfig resources.
+func WithSources(sources ...Source) Option {
+	return func(o *aggregator) {
+		o.sources = append(o.sources, sources...)
+	}
+}
+
+// WithStaleConfigGracePeriod sets how long to keep the last valid version of an application config after it becomes
+// invalid, 0 disables it.
+func WithStaleConfigGracePeriod(d time.Duration) Option {
+	return func(o *aggregator) {
+		o.staleConfigGracePeriod = d
+	}
+}
+
+// WithMaxRemovals sets the max percentage and the max number of the published application configs removed in one run,
+// 0 means no limit.
+func WithMaxRemovals(percent, configs int) Option {
+	return func(o *aggregator) {
+		o.maxRemovedPercent = percent
+		o.maxRemovedConfigs = configs
+	}
+}
+
+// WithDryRun sets whether to only compute and log the difference from the aggregated ConfigMap in each run,
+// without writing to the sinks or annotating the application config sources.
+func WithDryRun(d bool) Option {
+	return func(o *aggregator) {
+		o.dryRun = d
+	}
+}
@@ -0,0 +1,206 @@
+package aggregator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+
+	"github.com/numaproj-labs/numalogic-config-aggregator/pkg/apis/numalogic/v1alpha1"
+)
+
+// The key of the config entry converted from the spec of a NumalogicAppConfig.
+const appConfigResourceKey = "spec"
+
+func newAppConfigResourceSource(ac *v1alpha1.NumalogicAppConfig) *appSource {
+	return newAppSource(appConfigResourceSourceConfig(ac))
+}
+
+func appConfigResourceSourceConfig(ac *v1alpha1.NumalogicAppConfig) SourceConfig {
+	c := SourceConfig{
+		Kind:        KindNumalogicAppConfig,
+		Namespace:   ac.Namespace,
+		Name:        ac.Name,
+		Annotations: ac.Annotations,
+		Data:        map[string]string{},
+		Object:      ac,
+	}
+	appConfig, err := specToAppConfig(ac.Spec)
+	if err != nil {
+		c.Errors = append(c.Errors, fmt.Sprintf("%s: %v", appConfigResourceKey, err))
+		return c
+	}
+	b, err := yaml.Marshal(appConfig)
+	if err != nil {
+		c.Errors = append(c.Errors, fmt.Sprintf("%s: failed to marshal application config, %v", appConfigResourceKey, err))
+		return c
+	}
+	c.Data[appConfigResourceKey] = string(b)
+	return c
+}
+
+// The NumalogicAppConfig resources.
+type appConfigResourceSource struct {
+	a *aggregator
+}
+
+func (s *appConfigResourceSource) String() string {
+	return KindNumalogicAppConfig + "s"
+}
+
+func (s *appConfigResourceSource) Start(ctx context.Context, notify func()) error {
+	return s.a.startAppConfigResourceInformer(ctx, notify)
+}
+
+func (s *appConfigResourceSource) List(ctx context.Context) ([]SourceConfig, error) {
+	acs, err := s.a.listAppConfigResources(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s resources, %w", KindNumalogicAppConfig, err)
+	}
+	result := make([]SourceConfig, 0, len(acs))
+	for i := range acs {
+		result = append(result, appConfigResourceSourceConfig(&acs[i]))
+	}
+	return result, nil
+}
+
+// Convert the spec of a NumalogicAppConfig to an application config in the format of the schema.
+func specToAppConfig(spec v1alpha1.NumalogicAppConfigSpec) (obj, error) {
+	result := obj{}
+	if spec.Service != "" {
+		result["service"] = spec.Service
+	}
+	metricConfigs := []interface{}{}
+	for _, mc := range spec.MetricConfigs {
+		m := obj{"metric": mc.Metric}
+		if len(mc.CompositeKeys) > 0 {
+			m["composite_keys"] = toInterfaces(mc.CompositeKeys)
+		}
+		if mc.StaticThreshold != nil {
+			m["static_threshold"] = *mc.StaticThreshold
+		}
+		if mc.StaticThresholdWt != "" {
+			wt, err := strconv.ParseFloat(mc.StaticThresholdWt, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid staticThresholdWt %q of metric %q, %w", mc.StaticThresholdWt, mc.Metric, err)
+			}
+			m["static_threshold_wt"] = wt
+		}
+		if mc.ScrapeInterval != nil {
+			m["scrape_interval"] = *mc.ScrapeInterval
+		}
+		if mc.RetrainFreqHr != nil {
+			m["retrain_freq_hr"] = *mc.RetrainFreqHr
+		}
+		if mc.ResumeTraining != nil {
+			m["resume_training"] = *mc.ResumeTraining
+		}
+		if mc.NumalogicConf != nil && len(mc.NumalogicConf.Raw) > 0 {
+			var conf interface{}
+			if err := json.Unmarshal(mc.NumalogicConf.Raw, &conf); err != nil {
+				return nil, fmt.Errorf("invalid numalogicConf of metric %q, %w", mc.Metric, err)
+			}
+			m["numalogic_conf"] = conf
+		}
+		metricConfigs = append(metricConfigs, m)
+	}
+	result["metric_configs"] = metricConfigs
+	unifiedConfigs := []interface{}{}
+	for _, uc := range spec.UnifiedConfigs {
+		u := obj{
+			"unified_metric_name": uc.UnifiedMetricName,
+			"unified_metrics":     toInterfaces(uc.UnifiedMetrics),
+		}
+		if uc.UnifiedStrategy != "" {
+			u["unified_strategy"] = uc.UnifiedStrategy
+		}
+		unifiedConfigs = append(unifiedConfigs, u)
+	}
+	result["unified_configs"] = unifiedConfigs
+	return result, nil
+}
+
+func toInterfaces(l []string) []interface{} {
+	result := make([]interface{}, 0, len(l))
+	for _, s := range l {
+		result = append(result, s)
+	}
+	return result
+}
+
+// List the NumalogicAppConfig resources, from the informer cache if it's available.
+func (a *aggregator) listAppConfigResources(ctx context.Context) ([]v1alpha1.NumalogicAppConfig, error) {
+	var objs []runtime.Object
+	if len(a.acListers) > 0 {
+		for _, lister := range a.acListers {
+			l, err := lister.List(labels.Everything())
+			if err != nil {
+				return nil, err
+			}
+			objs = append(objs, l...)
+		}
+	} else {
+		for _, ns := range a.watchedNamespaces() {
+			l, err := a.dynamicClient.Resource(v1alpha1.NumalogicAppConfigGroupVersionResource).Namespace(ns).List(ctx, metav1.ListOptions{})
+			if err != nil {
+				return nil, fmt.Errorf("failed to list the %s resources in %s, %w", KindNumalogicAppConfig, namespaceName(ns), err)
+			}
+			for i := range l.Items {
+				objs = append(objs, &l.Items[i])
+			}
+		}
+	}
+	result := make([]v1alpha1.NumalogicAppConfig, 0, len(objs))
+	for _, o := range objs {
+		u, ok := o.(*unstructured.Unstructured)
+		if !ok {
+			return nil, fmt.Errorf("unexpected object type %T", o)
+		}
+		var ac v1alpha1.NumalogicAppConfig
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.UnstructuredContent(), &ac); err != nil {
+			return nil, fmt.Errorf("failed to convert %s %s/%s, %w", KindNumalogicAppConfig, u.GetNamespace(), u.GetName(), err)
+		}
+		result = append(result, ac)
+	}
+	return result, nil
+}
+
+// Update the status of the NumalogicAppConfig with the aggregation result.
+func (a *aggregator) updateAppConfigResourceStatus(ctx context.Context, src *appSource, ac *v1alpha1.NumalogicAppConfig) error {
+	status := ac.Status.DeepCopy()
+	if len(src.errors) > 0 {
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{Type: v1alpha1.ConditionValid, Status: metav1.ConditionFalse, Reason: ReasonInvalidConfig, Message: strings.Join(src.errors, "; "), ObservedGeneration: ac.Generation})
+	} else {
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{Type: v1alpha1.ConditionValid, Status: metav1.ConditionTrue, Reason: StatusValid, ObservedGeneration: ac.Generation})
+	}
+	if len(src.accepted) > 0 {
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{Type: v1alpha1.ConditionAggregated, Status: metav1.ConditionTrue, Reason: ReasonConfigAccepted, ObservedGeneration: ac.Generation})
+		status.ObservedGeneration = ac.Generation
+	} else {
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{Type: v1alpha1.ConditionAggregated, Status: metav1.ConditionFalse, Reason: src.status(), Message: "No config from the resource is in the aggregation", ObservedGeneration: ac.Generation})
+	}
+	if reflect.DeepEqual(status, &ac.Status) {
+		return nil
+	}
+	updated := ac.DeepCopy()
+	updated.Status = *status
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(updated)
+	if err != nil {
+		return fmt.Errorf("failed to convert %s to unstructured, %w", KindNumalogicAppConfig, err)
+	}
+	u := &unstructured.Unstructured{Object: content}
+	u.SetGroupVersionKind(v1alpha1.SchemeGroupVersion.WithKind(KindNumalogicAppConfig))
+	if _, err := a.dynamicClient.Resource(v1alpha1.NumalogicAppConfigGroupVersionResource).Namespace(ac.Namespace).UpdateStatus(ctx, u, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update the status of %s, %w", KindNumalogicAppConfig, err)
+	}
+	return nil
+}
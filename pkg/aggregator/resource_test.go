@@ -0,0 +1,140 @@
+package aggregator
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/yaml"
+
+	"github.com/numaproj-labs/numalogic-config-aggregator/pkg/apis/numalogic/v1alpha1"
+)
+
+func fakeAppConfigResource(t *testing.T, ns, name string) *v1alpha1.NumalogicAppConfig {
+	t.Helper()
+	threshold := int64(1)
+	return &v1alpha1.NumalogicAppConfig{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: v1alpha1.SchemeGroupVersion.String(),
+			Kind:       KindNumalogicAppConfig,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  ns,
+			Name:       name,
+			Generation: 1,
+		},
+		Spec: v1alpha1.NumalogicAppConfigSpec{
+			Service: "test",
+			MetricConfigs: []v1alpha1.MetricConfig{
+				{Metric: "m1", CompositeKeys: []string{"ck11", "ck12"}, StaticThreshold: &threshold, StaticThresholdWt: "0.5", NumalogicConf: &runtime.RawExtension{Raw: []byte(`{"model":{"name":"vae"}}`)}},
+			},
+			UnifiedConfigs: []v1alpha1.UnifiedConfig{
+				{UnifiedMetricName: "umn1", UnifiedMetrics: []string{"m1"}},
+			},
+		},
+	}
+}
+
+func fakeDynamicClient(t *testing.T, acs ...*v1alpha1.NumalogicAppConfig) *dynamicfake.FakeDynamicClient {
+	t.Helper()
+	objs := []runtime.Object{}
+	for _, ac := range acs {
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(ac)
+		assert.NoError(t, err)
+		objs = append(objs, &unstructured.Unstructured{Object: content})
+	}
+	return dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		v1alpha1.NumalogicAppConfigGroupVersionResource: "NumalogicAppConfigList",
+	}, objs...)
+}
+
+func Test_specToAppConfig(t *testing.T) {
+	appConfig, err := specToAppConfig(fakeAppConfigResource(t, "ns1", "n1").Spec)
+	assert.NoError(t, err)
+	b, err := yaml.Marshal(appConfig)
+	assert.NoError(t, err)
+	assert.Equal(t, `metric_configs:
+- composite_keys:
+  - ck11
+  - ck12
+  metric: m1
+  numalogic_conf:
+    model:
+      name: vae
+  static_threshold: 1
+  static_threshold_wt: 0.5
+service: test
+unified_configs:
+- unified_metric_name: umn1
+  unified_metrics:
+  - m1
+`, string(b))
+
+	spec := fakeAppConfigResource(t, "ns1", "n1").Spec
+	spec.MetricConfigs[0].StaticThresholdWt = "abc"
+	_, err = specToAppConfig(spec)
+	assert.Error(t, err)
+}
+
+func Test_runOnce_appConfigResources(t *testing.T) {
+	namespace := "test-ns"
+	cm := "test-cm"
+	k8sCli := k8sfake.NewSimpleClientset()
+	_, _ = k8sCli.CoreV1().ConfigMaps("ns1").Create(context.Background(), fakeAppConfigMap(t, "ns1", "n1"), metav1.CreateOptions{})
+	invalid := fakeAppConfigResource(t, "ns3", "invalid")
+	invalid.Spec.MetricConfigs[0].StaticThresholdWt = "abc"
+	dynamicCli := fakeDynamicClient(t, fakeAppConfigResource(t, "ns2", "valid"), invalid)
+	path, err := os.Getwd()
+	assert.NoError(t, err)
+	a := NewAggregator(k8sCli, namespace, cm, WithSchemaFileDir(path+"/../../manifests/install/base"), WithAppConfigResourceSource(dynamicCli))
+	assert.NoError(t, a.runOnce(context.Background()))
+
+	configMap, err := k8sCli.CoreV1().ConfigMaps(namespace).Get(context.Background(), cm, metav1.GetOptions{})
+	assert.NoError(t, err)
+	var c GlobalConfig
+	assert.NoError(t, yaml.Unmarshal([]byte(configMap.Data[defaultSettings.configMapKey]), &c))
+	assert.Equal(t, 2, len(c.Configs))
+	assert.Equal(t, "ns1", c.Configs[0][Namespace])
+	assert.Equal(t, "ns2", c.Configs[1][Namespace])
+
+	acs, err := a.listAppConfigResources(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(acs))
+	for _, ac := range acs {
+		switch ac.Name {
+		case "valid":
+			assert.Equal(t, int64(1), ac.Status.ObservedGeneration)
+			assert.True(t, meta.IsStatusConditionTrue(ac.Status.Conditions, v1alpha1.ConditionValid))
+			assert.True(t, meta.IsStatusConditionTrue(ac.Status.Conditions, v1alpha1.ConditionAggregated))
+		case "invalid":
+			assert.Equal(t, int64(0), ac.Status.ObservedGeneration)
+			assert.True(t, meta.IsStatusConditionFalse(ac.Status.Conditions, v1alpha1.ConditionValid))
+			assert.Contains(t, meta.FindStatusCondition(ac.Status.Conditions, v1alpha1.ConditionValid).Message, "staticThresholdWt")
+			assert.True(t, meta.IsStatusConditionFalse(ac.Status.Conditions, v1alpha1.ConditionAggregated))
+		}
+	}
+
+	// No status updates if nothing changes
+	dynamicCli.ClearActions()
+	assert.NoError(t, a.runOnce(context.Background()))
+	for _, action := range dynamicCli.Actions() {
+		assert.NotEqual(t, "update", action.GetVerb())
+	}
+
+	// Resources only
+	a = NewAggregator(k8sCli, namespace, cm, WithSchemaFileDir(path+"/../../manifests/install/base"), WithAppConfigResourceSource(dynamicCli), WithConfigMapSource(false))
+	assert.NoError(t, a.runOnce(context.Background()))
+	configMap, err = k8sCli.CoreV1().ConfigMaps(namespace).Get(context.Background(), cm, metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.NoError(t, yaml.Unmarshal([]byte(configMap.Data[defaultSettings.configMapKey]), &c))
+	assert.Equal(t, 1, len(c.Configs))
+	assert.Equal(t, "ns2", c.Configs[0][Namespace])
+}
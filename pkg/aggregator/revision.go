@@ -0,0 +1,234 @@
+package aggregator
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+)
+
+// DefaultRevisionHistoryLimit is the default number of the revisions of the aggregated config to keep.
+const DefaultRevisionHistoryLimit = 10
+
+// Revision is a revision of the aggregated config.
+type Revision struct {
+	Number int
+	// Name of the ConfigMap storing the revision
+	ConfigMap string
+	Time      time.Time
+	// The sha256 hash of the aggregated config
+	Hash string
+	// The application config sources changed in the revision
+	ChangedSources []string
+}
+
+func revisionName(configMap string, n int) string {
+	return fmt.Sprintf("%s-rev-%d", configMap, n)
+}
+
+// ListRevisions lists the revisions of an aggregated ConfigMap, ordered by the number.
+func ListRevisions(ctx context.Context, k8sclient kubernetes.Interface, namespace, configMap string) ([]Revision, error) {
+	cmList, err := k8sclient.CoreV1().ConfigMaps(namespace).List(ctx, metav1.ListOptions{LabelSelector: RevisionOfLabel + "=" + configMap})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the revisions, %w", err)
+	}
+	result := []Revision{}
+	for _, cm := range cmList.Items {
+		n, err := strconv.Atoi(cm.Annotations[RevisionAnnotation])
+		if err != nil {
+			continue
+		}
+		r := Revision{Number: n, ConfigMap: cm.Name, Hash: cm.Annotations[RevisionHashAnnotation]}
+		r.Time, _ = time.Parse(time.RFC3339, cm.Annotations[RevisionTimeAnnotation])
+		_ = json.Unmarshal([]byte(cm.Annotations[ChangedSourcesAnnotation]), &r.ChangedSources)
+		result = append(result, r)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Number < result[j].Number })
+	return result, nil
+}
+
+// Read the aggregated config of a revision.
+func readRevision(ctx context.Context, k8sclient kubernetes.Interface, namespace, configMap, key string, n int) ([]byte, error) {
+	cm, err := k8sclient.CoreV1().ConfigMaps(namespace).Get(ctx, revisionName(configMap, n), metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get revision %d, %w", n, err)
+	}
+	if content, ok := cm.Data[key]; ok {
+		return []byte(content), nil
+	}
+	// A large config is gzipped.
+	compressed, ok := cm.BinaryData[key+".gz"]
+	if !ok {
+		return nil, fmt.Errorf("revision %d has no key %q", n, key)
+	}
+	r, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress revision %d, %w", n, err)
+	}
+	defer r.Close()
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress revision %d, %w", n, err)
+	}
+	return content, nil
+}
+
+// PinRevision pins an aggregated ConfigMap to a revision, the aggregated config is rolled back to the revision,
+// and it's kept until it's unpinned.
+func PinRevision(ctx context.Context, k8sclient kubernetes.Interface, namespace, configMap, key string, n, maxConfigMapSize int) error {
+	content, err := readRevision(ctx, k8sclient, namespace, configMap, key, n)
+	if err != nil {
+		return err
+	}
+	config := GlobalConfig{}
+	if err := yaml.Unmarshal(content, &config); err != nil {
+		return fmt.Errorf("failed to parse revision %d, %w", n, err)
+	}
+	// Pin it before writing the content, so the aggregator does not overwrite it.
+	if err := patchPinnedRevision(ctx, k8sclient, namespace, configMap, strconv.Itoa(n)); err != nil {
+		return err
+	}
+	if _, err := NewConfigMapSink(k8sclient, namespace, configMap, key, maxConfigMapSize).Write(ctx, config, content); err != nil {
+		return fmt.Errorf("failed to roll back to revision %d, %w", n, err)
+	}
+	return nil
+}
+
+// UnpinRevision unpins an aggregated ConfigMap, it's updated with the latest aggregated config in the next run.
+func UnpinRevision(ctx context.Context, k8sclient kubernetes.Interface, namespace, configMap string) error {
+	return patchPinnedRevision(ctx, k8sclient, namespace, configMap, "")
+}
+
+func patchPinnedRevision(ctx context.Context, k8sclient kubernetes.Interface, namespace, configMap, revision string) error {
+	var value interface{}
+	if revision != "" {
+		value = revision
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{PinnedRevisionAnnotation: value},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := k8sclient.CoreV1().ConfigMaps(namespace).Patch(ctx, configMap, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		return fmt.Errorf("failed to patch the pinned revision of configmap %s, %w", configMap, err)
+	}
+	return nil
+}
+
+// The pinned revision of the aggregated ConfigMap, 0 if it's not pinned.
+func (a *aggregator) pinnedRevision(ctx context.Context) (int, error) {
+	cm, err := a.k8sclient.CoreV1().ConfigMaps(a.namespace).Get(ctx, a.configMap, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to get aggregated configmap, %w", err)
+	}
+	v, ok := cm.Annotations[PinnedRevisionAnnotation]
+	if !ok {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid pinned revision %q", v)
+	}
+	return n, nil
+}
+
+// The application config sources changed since the last revision, all the sources are regarded as changed
+// after the aggregator starts.
+func (a *aggregator) changedSources(sources []*appSource) (changed []string, hashes map[string]string) {
+	hashes = map[string]string{}
+	for _, src := range sources {
+		h, err := src.hash()
+		if err != nil {
+			continue
+		}
+		hashes[fmt.Sprintf("%s %s/%s", src.kind, src.namespace, src.name)] = h
+	}
+	for s, h := range hashes {
+		if a.sourceHashes == nil || a.sourceHashes[s] != h {
+			changed = append(changed, s)
+		}
+	}
+	for s := range a.sourceHashes {
+		if _, ok := hashes[s]; !ok {
+			changed = append(changed, s)
+		}
+	}
+	sort.Strings(changed)
+	return changed, hashes
+}
+
+// Record a revision of the aggregated config, and delete the ones beyond the history limit.
+func (a *aggregator) recordRevision(ctx context.Context, content []byte, changedSources []string) error {
+	revisions, err := ListRevisions(ctx, a.k8sclient, a.namespace, a.configMap)
+	if err != nil {
+		return err
+	}
+	n := 1
+	if len(revisions) > 0 {
+		n = revisions[len(revisions)-1].Number + 1
+	}
+	sum := sha256.Sum256(content)
+	changed, err := json.Marshal(changedSources)
+	if err != nil {
+		return err
+	}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: a.namespace,
+			Name:      revisionName(a.configMap, n),
+			Labels:    map[string]string{RevisionOfLabel: a.configMap},
+			Annotations: map[string]string{
+				managedByAnnotation:      "numalogic-config-aggregator",
+				RevisionAnnotation:       strconv.Itoa(n),
+				RevisionTimeAnnotation:   time.Now().UTC().Format(time.RFC3339),
+				RevisionHashAnnotation:   hex.EncodeToString(sum[:]),
+				ChangedSourcesAnnotation: string(changed),
+			},
+		},
+	}
+	if a.maxConfigMapSize <= 0 || len(content) <= a.maxConfigMapSize {
+		cm.Data = map[string]string{a.configMapKey: string(content)}
+	} else {
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(content); err != nil {
+			return fmt.Errorf("failed to compress the revision, %w", err)
+		}
+		if err := w.Close(); err != nil {
+			return fmt.Errorf("failed to compress the revision, %w", err)
+		}
+		cm.BinaryData = map[string][]byte{a.configMapKey + ".gz": buf.Bytes()}
+	}
+	if _, err := a.k8sclient.CoreV1().ConfigMaps(a.namespace).Create(ctx, cm, metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("failed to create revision %d, %w", n, err)
+	}
+	a.logger.Infow("Recorded a revision of the aggregated config", zap.Int("revision", n), zap.Strings("changedSources", changedSources))
+	revisions = append(revisions, Revision{Number: n, ConfigMap: cm.Name})
+	for i := 0; i < len(revisions)-a.revisionHistoryLimit; i++ {
+		if err := a.k8sclient.CoreV1().ConfigMaps(a.namespace).Delete(ctx, revisions[i].ConfigMap, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete revision %d, %w", revisions[i].Number, err)
+		}
+	}
+	return nil
+}
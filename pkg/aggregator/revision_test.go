@@ -0,0 +1,76 @@
+package aggregator
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_revisions(t *testing.T) {
+	k8sCli := k8sfake.NewSimpleClientset()
+	ctx := context.Background()
+	path, err := os.Getwd()
+	assert.NoError(t, err)
+	a := NewAggregator(k8sCli, "test-ns", "test-cm", WithSchemaFileDir(path+"/../../manifests/install/base"), WithRevisionHistoryLimit(2))
+	getConfig := func() string {
+		cm, err := k8sCli.CoreV1().ConfigMaps("test-ns").Get(ctx, "test-cm", metav1.GetOptions{})
+		assert.NoError(t, err)
+		return cm.Data[defaultSettings.configMapKey]
+	}
+
+	_, err = k8sCli.CoreV1().ConfigMaps("ns1").Create(ctx, fakeAppConfigMap(t, "ns1", "n1"), metav1.CreateOptions{})
+	assert.NoError(t, err)
+	assert.NoError(t, a.runOnce(ctx))
+	first := getConfig()
+	// No revision without changes.
+	assert.NoError(t, a.runOnce(ctx))
+	_, err = k8sCli.CoreV1().ConfigMaps("ns2").Create(ctx, fakeAppConfigMap(t, "ns2", "n2"), metav1.CreateOptions{})
+	assert.NoError(t, err)
+	assert.NoError(t, a.runOnce(ctx))
+	revisions, err := ListRevisions(ctx, k8sCli, "test-ns", "test-cm")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(revisions))
+	assert.Equal(t, "test-cm-rev-2", revisions[1].ConfigMap)
+	assert.Equal(t, []string{"ConfigMap ns1/n1"}, revisions[0].ChangedSources)
+	assert.Equal(t, []string{"ConfigMap ns2/n2"}, revisions[1].ChangedSources)
+	assert.NotEmpty(t, revisions[1].Hash)
+
+	// The oldest revision is deleted beyond the limit.
+	assert.NoError(t, k8sCli.CoreV1().ConfigMaps("ns2").Delete(ctx, "n2", metav1.DeleteOptions{}))
+	assert.NoError(t, a.runOnce(ctx))
+	revisions, err = ListRevisions(ctx, k8sCli, "test-ns", "test-cm")
+	assert.NoError(t, err)
+	assert.Equal(t, []int{2, 3}, []int{revisions[0].Number, revisions[1].Number})
+	assert.Equal(t, []string{"ConfigMap ns2/n2"}, revisions[1].ChangedSources)
+	assert.Equal(t, first, getConfig())
+
+	// The pinned revision is kept until it's unpinned.
+	assert.NoError(t, PinRevision(ctx, k8sCli, "test-ns", "test-cm", defaultSettings.configMapKey, 2, DefaultMaxConfigMapSize))
+	second := getConfig()
+	assert.NotEqual(t, first, second)
+	assert.NoError(t, a.runOnce(ctx))
+	assert.Equal(t, second, getConfig())
+	assert.NoError(t, UnpinRevision(ctx, k8sCli, "test-ns", "test-cm"))
+	assert.NoError(t, a.runOnce(ctx))
+	assert.Equal(t, first, getConfig())
+
+	assert.Error(t, PinRevision(ctx, k8sCli, "test-ns", "test-cm", defaultSettings.configMapKey, 1, DefaultMaxConfigMapSize))
+}
+
+func Test_recordRevision_compressed(t *testing.T) {
+	k8sCli := k8sfake.NewSimpleClientset()
+	ctx := context.Background()
+	a := NewAggregator(k8sCli, "ns", "cm", WithMaxConfigMapSize(10))
+	assert.NoError(t, a.recordRevision(ctx, []byte("configs:\n- name: test\n"), nil))
+	cm, err := k8sCli.CoreV1().ConfigMaps("ns").Get(ctx, "cm-rev-1", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Empty(t, cm.Data)
+	assert.Contains(t, cm.BinaryData, defaultSettings.configMapKey+".gz")
+	content, err := readRevision(ctx, k8sCli, "ns", "cm", defaultSettings.configMapKey, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "configs:\n- name: test\n", string(content))
+}
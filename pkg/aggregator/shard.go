@@ -0,0 +1,44 @@
+package aggregator
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/yaml"
+)
+
+// DefaultMaxConfigMapSize is the default max size of the aggregated config in one ConfigMap, leaving some
+// room for the metadata under the 1 MiB size limit of a ConfigMap.
+const DefaultMaxConfigMapSize = 900 * 1024
+
+// Split the aggregated config into the shards no larger than the max size, it fails if a single config
+// is larger than the max size.
+func shardConfig(config GlobalConfig, maxSize int) ([]GlobalConfig, error) {
+	shards := []GlobalConfig{}
+	current := GlobalConfig{Configs: []obj{}}
+	// The size of each config marshalled alone is a bit larger than its part in a shard, so the sum is an upper bound.
+	size := 0
+	for _, c := range config.Configs {
+		b, err := yaml.Marshal(&GlobalConfig{Configs: []obj{c}})
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal configuration, %w", err)
+		}
+		if len(b) > maxSize {
+			return nil, fmt.Errorf("the config of service %v in namespace %v is %d bytes, larger than the max size %d of a ConfigMap", c["service"], c[Namespace], len(b), maxSize)
+		}
+		if size+len(b) > maxSize && len(current.Configs) > 0 {
+			shards = append(shards, current)
+			current = GlobalConfig{Configs: []obj{}}
+			size = 0
+		}
+		current.Configs = append(current.Configs, c)
+		size += len(b)
+	}
+	if len(current.Configs) > 0 {
+		shards = append(shards, current)
+	}
+	return shards, nil
+}
+
+func shardName(configMap string, i int) string {
+	return fmt.Sprintf("%s-%d", configMap, i)
+}
@@ -0,0 +1,94 @@
+package aggregator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/yaml"
+)
+
+func fakeLargeConfig(n, size int) GlobalConfig {
+	config := GlobalConfig{Configs: []obj{}}
+	for i := 0; i < n; i++ {
+		config.Configs = append(config.Configs, obj{Namespace: fmt.Sprintf("ns%d", i), "service": strings.Repeat("s", size)})
+	}
+	return config
+}
+
+func Test_shardConfig(t *testing.T) {
+	config := fakeLargeConfig(10, 100)
+	shards, err := shardConfig(config, 300)
+	assert.NoError(t, err)
+	total := 0
+	for _, shard := range shards {
+		b, err := yaml.Marshal(&shard)
+		assert.NoError(t, err)
+		assert.LessOrEqual(t, len(b), 300)
+		total += len(shard.Configs)
+	}
+	assert.Equal(t, 10, total)
+	assert.Equal(t, 5, len(shards))
+	assert.Equal(t, "ns2", shards[1].Configs[0][Namespace])
+
+	_, err = shardConfig(config, 100)
+	assert.Error(t, err)
+}
+
+func Test_configMapSink_sharding(t *testing.T) {
+	k8sCli := k8sfake.NewSimpleClientset()
+	ctx := context.Background()
+	s := newConfigMapSink(k8sCli, "ns", "cm", "config.yaml", 300)
+	write := func(config GlobalConfig) {
+		content, err := yaml.Marshal(&config)
+		assert.NoError(t, err)
+		changed, err := s.Write(ctx, config, content)
+		assert.NoError(t, err)
+		assert.True(t, changed)
+	}
+	shardNames := func() []string {
+		l, err := k8sCli.CoreV1().ConfigMaps("ns").List(ctx, metav1.ListOptions{LabelSelector: ShardOfLabel + "=cm"})
+		assert.NoError(t, err)
+		var names []string
+		for _, cm := range l.Items {
+			names = append(names, cm.Name)
+		}
+		return names
+	}
+
+	write(fakeLargeConfig(1, 10))
+	assert.Equal(t, 0, s.shards)
+	assert.Empty(t, shardNames())
+
+	write(fakeLargeConfig(6, 100))
+	assert.Equal(t, 3, s.shards)
+	assert.ElementsMatch(t, []string{"cm-0", "cm-1", "cm-2"}, shardNames())
+	cm, err := k8sCli.CoreV1().ConfigMaps("ns").Get(ctx, "cm", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.NotContains(t, cm.Data, "config.yaml")
+	var index ShardIndex
+	assert.NoError(t, yaml.Unmarshal([]byte(cm.Data[ShardIndexKey]), &index))
+	assert.Equal(t, 3, len(index.Shards))
+	assert.Equal(t, Shard{ConfigMap: "cm-1", Key: "config.yaml", Configs: 2}, index.Shards[1])
+	shard, err := k8sCli.CoreV1().ConfigMaps("ns").Get(ctx, "cm-2", metav1.GetOptions{})
+	assert.NoError(t, err)
+	var c GlobalConfig
+	assert.NoError(t, yaml.Unmarshal([]byte(shard.Data["config.yaml"]), &c))
+	assert.Equal(t, "ns4", c.Configs[0][Namespace])
+
+	write(fakeLargeConfig(4, 100))
+	assert.Equal(t, 2, s.shards)
+	assert.ElementsMatch(t, []string{"cm-0", "cm-1"}, shardNames())
+
+	write(fakeLargeConfig(1, 10))
+	assert.Equal(t, 0, s.shards)
+	assert.Empty(t, shardNames())
+	cm, err = k8sCli.CoreV1().ConfigMaps("ns").Get(ctx, "cm", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.NotContains(t, cm.Data, ShardIndexKey)
+	assert.Contains(t, cm.Data, "config.yaml")
+}
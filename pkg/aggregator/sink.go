@@ -0,0 +1,354 @@
+package aggregator
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/yaml"
+)
+
+// Sink is a destination of the aggregated config.
+type Sink interface {
+	// String returns the name of the sink, used in the logs.
+	String() string
+	// Write writes the aggregated config, and its YAML content, to the sink, it returns
+	// whether the content is changed.
+	Write(ctx context.Context, config GlobalConfig, content []byte) (bool, error)
+}
+
+const managedByAnnotation = "app.kubernetes.io/managed-by"
+
+type configMapSink struct {
+	k8sclient kubernetes.Interface
+	namespace string
+	name      string
+	key       string
+	// The max size of the aggregated config in one ConfigMap, it's split into the shards if it's larger
+	maxSize int
+	// The number of the shards in the last write, 0 if it's not sharded, -1 if it's unknown
+	shards int
+}
+
+// NewConfigMapSink returns a sink writing the aggregated config to a key of a ConfigMap. If the aggregated
+// config is larger than the maxSize, it's split into the shards "<name>-0", "<name>-1", ..., and a ShardIndex is
+// written to the ConfigMap instead. It's never sharded if the maxSize is not positive.
+func NewConfigMapSink(k8sclient kubernetes.Interface, namespace, name, key string, maxSize int) Sink {
+	return newConfigMapSink(k8sclient, namespace, name, key, maxSize)
+}
+
+func newConfigMapSink(k8sclient kubernetes.Interface, namespace, name, key string, maxSize int) *configMapSink {
+	return &configMapSink{k8sclient: k8sclient, namespace: namespace, name: name, key: key, maxSize: maxSize, shards: -1}
+}
+
+func (s *configMapSink) String() string {
+	return fmt.Sprintf("ConfigMap %s/%s", s.namespace, s.name)
+}
+
+func (s *configMapSink) Write(ctx context.Context, config GlobalConfig, content []byte) (bool, error) {
+	annotations, err := provenanceAnnotations(config.Provenance)
+	if err != nil {
+		return false, err
+	}
+	if s.maxSize <= 0 || len(content) <= s.maxSize {
+		changed, err := s.writeConfigMap(ctx, s.name, map[string]string{s.key: string(content)}, nil, annotations, ShardIndexKey)
+		if err != nil {
+			return false, err
+		}
+		deleted := false
+		// No need to look for the shards if it's known to be not sharded.
+		if s.shards != 0 {
+			if deleted, err = s.deleteShards(ctx, 0); err != nil {
+				return changed, err
+			}
+		}
+		s.shards = 0
+		return changed || deleted, nil
+	}
+	shards, err := shardConfig(config, s.maxSize)
+	if err != nil {
+		return false, err
+	}
+	sum := sha256.Sum256(content)
+	index := ShardIndex{Hash: hex.EncodeToString(sum[:]), Stale: config.Stale}
+	changed := false
+	for i, shard := range shards {
+		shardBytes, err := yaml.Marshal(&shard)
+		if err != nil {
+			return false, fmt.Errorf("failed to marshal configuration, %w", err)
+		}
+		name := shardName(s.name, i)
+		c, err := s.writeConfigMap(ctx, name, map[string]string{s.key: string(shardBytes)}, map[string]string{ShardOfLabel: s.name}, nil)
+		if err != nil {
+			return changed, err
+		}
+		changed = changed || c
+		index.Shards = append(index.Shards, Shard{ConfigMap: name, Key: s.key, Configs: len(shard.Configs)})
+	}
+	indexBytes, err := yaml.Marshal(&index)
+	if err != nil {
+		return changed, fmt.Errorf("failed to marshal shard index, %w", err)
+	}
+	// Write the index after the shards, so it never refers to a missing shard.
+	c, err := s.writeConfigMap(ctx, s.name, map[string]string{ShardIndexKey: string(indexBytes)}, nil, annotations, s.key)
+	if err != nil {
+		return changed, err
+	}
+	changed = changed || c
+	deleted, err := s.deleteShards(ctx, len(shards))
+	if err != nil {
+		return changed, err
+	}
+	s.shards = len(shards)
+	return changed || deleted, nil
+}
+
+// Create or update a ConfigMap with the data and the labels, the removedKeys are removed from it.
+// The annotations are only set along with a change of the data, an empty value removes the annotation.
+// It returns whether the ConfigMap is changed. The update is based on the resourceVersion of the ConfigMap
+// just read, it's retried on a conflict with the other writers.
+func (s *configMapSink) writeConfigMap(ctx context.Context, name string, data, labels, annotations map[string]string, removedKeys ...string) (bool, error) {
+	changed := false
+	err := retryOnConflict(func() error {
+		changed = false
+		cm, err := s.k8sclient.CoreV1().ConfigMaps(s.namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				cm = &corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{
+						Namespace: s.namespace,
+						Name:      name,
+						Labels:    labels,
+						Annotations: map[string]string{
+							managedByAnnotation: "numalogic-config-aggregator",
+						},
+					},
+					Data: data,
+				}
+				for k, v := range annotations {
+					if v != "" {
+						cm.Annotations[k] = v
+					}
+				}
+				if _, err := s.k8sclient.CoreV1().ConfigMaps(s.namespace).Create(ctx, cm, metav1.CreateOptions{}); err != nil {
+					return fmt.Errorf("failed to create aggregated configmap %s, %w", name, err)
+				}
+				changed = true
+				return nil
+			}
+			return fmt.Errorf("failed to get aggregated configmap %s, %w", name, err)
+		}
+		// The data is nil if the ConfigMap is emptied manually.
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		for k, v := range data {
+			if current, ok := cm.Data[k]; !ok || current != v {
+				cm.Data[k] = v
+				changed = true
+			}
+		}
+		for _, k := range removedKeys {
+			if _, ok := cm.Data[k]; ok {
+				delete(cm.Data, k)
+				changed = true
+			}
+		}
+		if !changed {
+			return nil
+		}
+		for k, v := range annotations {
+			if v == "" {
+				delete(cm.Annotations, k)
+				continue
+			}
+			if cm.Annotations == nil {
+				cm.Annotations = map[string]string{}
+			}
+			cm.Annotations[k] = v
+		}
+		if _, err := s.k8sclient.CoreV1().ConfigMaps(s.namespace).Update(ctx, cm, metav1.UpdateOptions{}); err != nil {
+			changed = false
+			return fmt.Errorf("failed to update aggregated configmap %s, %w", name, err)
+		}
+		return nil
+	})
+	return changed, err
+}
+
+// Retry the read-modify-write fn on a conflict, including creating an object just created by the others.
+func retryOnConflict(fn func() error) error {
+	return retry.OnError(retry.DefaultRetry, func(err error) bool {
+		return apierrors.IsConflict(err) || apierrors.IsAlreadyExists(err)
+	}, fn)
+}
+
+// Read the aggregated config in the ConfigMap, concatenating the shards if it's sharded. It returns
+// false if there's no aggregated config yet.
+func (s *configMapSink) read(ctx context.Context) (GlobalConfig, bool, error) {
+	config := GlobalConfig{}
+	cm, err := s.k8sclient.CoreV1().ConfigMaps(s.namespace).Get(ctx, s.name, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return config, false, nil
+		}
+		return config, false, fmt.Errorf("failed to get aggregated configmap %s, %w", s.name, err)
+	}
+	if v, ok := cm.Annotations[ProvenanceAnnotation]; ok {
+		if err := json.Unmarshal([]byte(v), &config.Provenance); err != nil {
+			return config, false, fmt.Errorf("failed to parse the provenance of aggregated configmap %s, %w", s.name, err)
+		}
+	}
+	if content, ok := cm.Data[s.key]; ok {
+		if err := yaml.Unmarshal([]byte(content), &config); err != nil {
+			return config, false, fmt.Errorf("failed to parse aggregated configmap %s, %w", s.name, err)
+		}
+		return config, true, nil
+	}
+	indexContent, ok := cm.Data[ShardIndexKey]
+	if !ok {
+		return config, false, nil
+	}
+	var index ShardIndex
+	if err := yaml.Unmarshal([]byte(indexContent), &index); err != nil {
+		return config, false, fmt.Errorf("failed to parse the shard index of aggregated configmap %s, %w", s.name, err)
+	}
+	config.Stale = index.Stale
+	for _, shard := range index.Shards {
+		shardCm, err := s.k8sclient.CoreV1().ConfigMaps(s.namespace).Get(ctx, shard.ConfigMap, metav1.GetOptions{})
+		if err != nil {
+			return config, false, fmt.Errorf("failed to get the shard %s, %w", shard.ConfigMap, err)
+		}
+		var c GlobalConfig
+		if err := yaml.Unmarshal([]byte(shardCm.Data[shard.Key]), &c); err != nil {
+			return config, false, fmt.Errorf("failed to parse the shard %s, %w", shard.ConfigMap, err)
+		}
+		config.Configs = append(config.Configs, c.Configs...)
+	}
+	return config, true, nil
+}
+
+// The annotations recording the provenance of the aggregated config, the annotation is removed if there's none.
+func provenanceAnnotations(provenance []Provenance) (map[string]string, error) {
+	p, err := provenanceJSON(provenance)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{ProvenanceAnnotation: p}, nil
+}
+
+// The provenance of the aggregated config in JSON, empty if there's none.
+func provenanceJSON(provenance []Provenance) (string, error) {
+	if len(provenance) == 0 {
+		return "", nil
+	}
+	b, err := json.Marshal(provenance)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal the provenance, %w", err)
+	}
+	return string(b), nil
+}
+
+// Delete the shards from the index "from", it returns whether any shard is deleted.
+func (s *configMapSink) deleteShards(ctx context.Context, from int) (bool, error) {
+	cmList, err := s.k8sclient.CoreV1().ConfigMaps(s.namespace).List(ctx, metav1.ListOptions{LabelSelector: ShardOfLabel + "=" + s.name})
+	if err != nil {
+		return false, fmt.Errorf("failed to list the shards, %w", err)
+	}
+	current := map[string]bool{}
+	for i := 0; i < from; i++ {
+		current[shardName(s.name, i)] = true
+	}
+	deleted := false
+	for _, cm := range cmList.Items {
+		if current[cm.Name] {
+			continue
+		}
+		if err := s.k8sclient.CoreV1().ConfigMaps(s.namespace).Delete(ctx, cm.Name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return deleted, fmt.Errorf("failed to delete the shard %s, %w", cm.Name, err)
+		}
+		deleted = true
+	}
+	return deleted, nil
+}
+
+type secretSink struct {
+	k8sclient kubernetes.Interface
+	namespace string
+	name      string
+	key       string
+}
+
+// NewSecretSink returns a sink writing the aggregated config to a key of a Secret.
+func NewSecretSink(k8sclient kubernetes.Interface, namespace, name, key string) Sink {
+	return &secretSink{k8sclient: k8sclient, namespace: namespace, name: name, key: key}
+}
+
+func (s *secretSink) String() string {
+	return fmt.Sprintf("Secret %s/%s", s.namespace, s.name)
+}
+
+func (s *secretSink) Write(ctx context.Context, config GlobalConfig, content []byte) (bool, error) {
+	provenance, err := provenanceJSON(config.Provenance)
+	if err != nil {
+		return false, err
+	}
+	changed := false
+	err = retryOnConflict(func() error {
+		changed = false
+		secret, err := s.k8sclient.CoreV1().Secrets(s.namespace).Get(ctx, s.name, metav1.GetOptions{})
+		if err != nil {
+			if !apierrors.IsNotFound(err) {
+				return fmt.Errorf("failed to get aggregated secret, %w", err)
+			}
+			secret = &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: s.namespace,
+					Name:      s.name,
+					Annotations: map[string]string{
+						managedByAnnotation: "numalogic-config-aggregator",
+					},
+				},
+				Data: map[string][]byte{
+					s.key: content,
+				},
+			}
+			if provenance != "" {
+				secret.Annotations[ProvenanceAnnotation] = provenance
+			}
+			if _, err := s.k8sclient.CoreV1().Secrets(s.namespace).Create(ctx, secret, metav1.CreateOptions{}); err != nil {
+				return fmt.Errorf("failed to create aggregated secret, %w", err)
+			}
+			changed = true
+			return nil
+		}
+		if current, ok := secret.Data[s.key]; ok && string(content) == string(current) {
+			return nil
+		}
+		if secret.Data == nil {
+			secret.Data = map[string][]byte{}
+		}
+		secret.Data[s.key] = content
+		if provenance == "" {
+			delete(secret.Annotations, ProvenanceAnnotation)
+		} else {
+			if secret.Annotations == nil {
+				secret.Annotations = map[string]string{}
+			}
+			secret.Annotations[ProvenanceAnnotation] = provenance
+		}
+		if _, err := s.k8sclient.CoreV1().Secrets(s.namespace).Update(ctx, secret, metav1.UpdateOptions{}); err != nil {
+			return fmt.Errorf("failed to update aggregated secret, %w", err)
+		}
+		changed = true
+		return nil
+	})
+	return changed, err
+}
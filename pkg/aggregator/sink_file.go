@@ -0,0 +1,74 @@
+package aggregator
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+type fileSink struct {
+	path string
+}
+
+// NewFileSink returns a sink writing the aggregated config to a local file, e.g. in a volume shared with a sidecar.
+// The provenance is written to the file with the ProvenanceFileSuffix next to it, before the config.
+func NewFileSink(path string) Sink {
+	return &fileSink{path: path}
+}
+
+func (s *fileSink) String() string {
+	return fmt.Sprintf("file %s", s.path)
+}
+
+func (s *fileSink) Write(_ context.Context, config GlobalConfig, content []byte) (bool, error) {
+	existing, err := os.ReadFile(s.path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return false, fmt.Errorf("failed to read %s, %w", s.path, err)
+	}
+	if err == nil && bytes.Equal(existing, content) {
+		return false, nil
+	}
+	provenance, err := provenanceJSON(config.Provenance)
+	if err != nil {
+		return false, err
+	}
+	provenancePath := s.path + ProvenanceFileSuffix
+	if provenance == "" {
+		if err := os.Remove(provenancePath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return false, fmt.Errorf("failed to remove %s, %w", provenancePath, err)
+		}
+	} else if err := writeFile(provenancePath, []byte(provenance)); err != nil {
+		return false, err
+	}
+	if err := writeFile(s.path, content); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Write to a temp file and rename it, so the readers never see a partial file.
+func writeFile(path string, content []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file, %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write %s, %w", tmp.Name(), err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s, %w", tmp.Name(), err)
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return fmt.Errorf("failed to chmod %s, %w", tmp.Name(), err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to rename %s to %s, %w", tmp.Name(), path, err)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package aggregator
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type httpSink struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+
+	lock sync.Mutex
+	// The hash of the content of the last successful POST
+	lastHash string
+}
+
+// NewHTTPSink returns a sink POSTing the aggregated config to a webhook URL when it's changed,
+// the content is POSTed once after the aggregator starts. The provenance is sent in the ProvenanceHeader.
+func NewHTTPSink(url string, headers map[string]string, timeout time.Duration) Sink {
+	return &httpSink{url: url, headers: headers, client: &http.Client{Timeout: timeout}}
+}
+
+func (s *httpSink) String() string {
+	return fmt.Sprintf("http %s", s.url)
+}
+
+func (s *httpSink) Write(ctx context.Context, config GlobalConfig, content []byte) (bool, error) {
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if hash == s.lastHash {
+		return false, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(content))
+	if err != nil {
+		return false, fmt.Errorf("failed to create request, %w", err)
+	}
+	req.Header.Set("Content-Type", "application/yaml")
+	provenance, err := provenanceJSON(config.Provenance)
+	if err != nil {
+		return false, err
+	}
+	if provenance != "" {
+		req.Header.Set(ProvenanceHeader, provenance)
+	}
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to post aggregated config, %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return false, fmt.Errorf("failed to post aggregated config, status code %d", resp.StatusCode)
+	}
+	s.lastHash = hash
+	return true, nil
+}
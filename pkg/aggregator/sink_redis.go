@@ -0,0 +1,117 @@
+package aggregator
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+	"sigs.k8s.io/yaml"
+)
+
+type redisSink struct {
+	client redis.UniversalClient
+	prefix string
+	// Whether to write one key for each service besides the whole aggregated config
+	perServiceKeys bool
+}
+
+// RedisNotification is the message published to the "<prefix>:updates" channel when the aggregated config is changed.
+type RedisNotification struct {
+	Version int64  `json:"version"`
+	Hash    string `json:"hash"`
+}
+
+// NewRedisSink returns a sink writing the aggregated config to Redis, with the keys:
+//
+//   - "<prefix>:config": the aggregated config.
+//   - "<prefix>:hash": the sha256 hash of the aggregated config.
+//   - "<prefix>:version": the version incremented on each change.
+//   - "<prefix>:provenance": the provenance of the aggregated config in JSON, if there's any.
+//   - "<prefix>:config:<namespace>:<service>": the config of each service, if perServiceKeys is enabled.
+//
+// A RedisNotification is published to the "<prefix>:updates" channel on each change.
+func NewRedisSink(client redis.UniversalClient, prefix string, perServiceKeys bool) Sink {
+	return &redisSink{client: client, prefix: prefix, perServiceKeys: perServiceKeys}
+}
+
+func (s *redisSink) String() string {
+	return fmt.Sprintf("redis %s", s.prefix)
+}
+
+func (s *redisSink) key(parts ...string) string {
+	k := s.prefix
+	for _, p := range parts {
+		k += ":" + p
+	}
+	return k
+}
+
+func (s *redisSink) Write(ctx context.Context, config GlobalConfig, content []byte) (bool, error) {
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])
+	existing, err := s.client.Get(ctx, s.key("hash")).Result()
+	if err != nil && err != redis.Nil {
+		return false, fmt.Errorf("failed to get the hash, %w", err)
+	}
+	if existing == hash {
+		return false, nil
+	}
+	provenance, err := provenanceJSON(config.Provenance)
+	if err != nil {
+		return false, err
+	}
+	serviceConfigs := map[string]string{}
+	if s.perServiceKeys {
+		for _, c := range config.Configs {
+			b, err := yaml.Marshal(c)
+			if err != nil {
+				return false, fmt.Errorf("failed to marshal configuration, %w", err)
+			}
+			serviceConfigs[s.key("config", fmt.Sprint(c[Namespace]), fmt.Sprint(c["service"]))] = string(b)
+		}
+	}
+	// The keys of the services written last time, the stale ones are deleted.
+	serviceKeys, err := s.client.SMembers(ctx, s.key("services")).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to get the service keys, %w", err)
+	}
+	var version *redis.IntCmd
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, s.key("config"), content, 0)
+		if provenance == "" {
+			pipe.Del(ctx, s.key("provenance"))
+		} else {
+			pipe.Set(ctx, s.key("provenance"), provenance, 0)
+		}
+		for _, k := range serviceKeys {
+			if _, ok := serviceConfigs[k]; !ok {
+				pipe.Del(ctx, k)
+			}
+		}
+		pipe.Del(ctx, s.key("services"))
+		for k, v := range serviceConfigs {
+			pipe.Set(ctx, k, v, 0)
+			pipe.SAdd(ctx, s.key("services"), k)
+		}
+		version = pipe.Incr(ctx, s.key("version"))
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to write the aggregated config, %w", err)
+	}
+	msg, err := json.Marshal(RedisNotification{Version: version.Val(), Hash: hash})
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal the notification, %w", err)
+	}
+	if err := s.client.Publish(ctx, s.key("updates"), msg).Err(); err != nil {
+		return false, fmt.Errorf("failed to publish the notification, %w", err)
+	}
+	// Set the hash at last, so it's written and published again in the next run if anything fails.
+	if err := s.client.Set(ctx, s.key("hash"), hash, 0).Err(); err != nil {
+		return false, fmt.Errorf("failed to set the hash, %w", err)
+	}
+	return true, nil
+}
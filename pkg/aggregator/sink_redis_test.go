@@ -0,0 +1,77 @@
+package aggregator
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/yaml"
+)
+
+func Test_redisSink(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	ctx := context.Background()
+	sub := client.Subscribe(ctx, "test:updates")
+	defer sub.Close()
+	_, err := sub.Receive(ctx)
+	assert.NoError(t, err)
+
+	s := NewRedisSink(client, "test", true)
+	write := func(config GlobalConfig) bool {
+		content, err := yaml.Marshal(&config)
+		assert.NoError(t, err)
+		changed, err := s.Write(ctx, config, content)
+		assert.NoError(t, err)
+		return changed
+	}
+	receive := func() RedisNotification {
+		var n RedisNotification
+		select {
+		case msg := <-sub.Channel():
+			assert.NoError(t, json.Unmarshal([]byte(msg.Payload), &n))
+		case <-time.After(5 * time.Second):
+			t.Fatal("no notification")
+		}
+		return n
+	}
+
+	config := GlobalConfig{Configs: []obj{
+		{Namespace: "ns1", "service": "s1"},
+		{Namespace: "ns2", "service": "s2"},
+	}}
+	assert.True(t, write(config))
+	assert.Equal(t, int64(1), receive().Version)
+	assert.False(t, write(config))
+	v, _ := mr.Get("test:version")
+	assert.Equal(t, "1", v)
+	assert.True(t, mr.Exists("test:config:ns1:s1"))
+	assert.True(t, mr.Exists("test:config:ns2:s2"))
+	c, _ := mr.Get("test:config")
+	assert.Contains(t, c, "s1")
+
+	assert.False(t, mr.Exists("test:provenance"))
+
+	config.Configs = config.Configs[1:]
+	config.Provenance = []Provenance{{Source: "git /repo@main", Version: "abc"}}
+	assert.True(t, write(config))
+	n := receive()
+	assert.Equal(t, int64(2), n.Version)
+	h, _ := mr.Get("test:hash")
+	assert.Equal(t, h, n.Hash)
+	assert.False(t, mr.Exists("test:config:ns1:s1"))
+	assert.True(t, mr.Exists("test:config:ns2:s2"))
+	p, _ := mr.Get("test:provenance")
+	assert.Equal(t, `[{"source":"git /repo@main","version":"abc"}]`, p)
+
+	mr.Close()
+	config.Configs = nil
+	content, _ := yaml.Marshal(&config)
+	_, err = s.Write(ctx, config, content)
+	assert.Error(t, err)
+}
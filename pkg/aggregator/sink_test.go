@@ -0,0 +1,146 @@
+package aggregator
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/numaproj-labs/numalogic-config-aggregator/pkg/metrics"
+)
+
+func Test_secretSink(t *testing.T) {
+	k8sCli := k8sfake.NewSimpleClientset()
+	s := NewSecretSink(k8sCli, "ns", "secret", "config.yaml")
+	assert.Equal(t, "Secret ns/secret", s.String())
+	changed, err := s.Write(context.Background(), GlobalConfig{}, []byte("a"))
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	changed, err = s.Write(context.Background(), GlobalConfig{}, []byte("a"))
+	assert.NoError(t, err)
+	assert.False(t, changed)
+	changed, err = s.Write(context.Background(), GlobalConfig{Provenance: testProvenance}, []byte("b"))
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	secret, err := k8sCli.CoreV1().Secrets("ns").Get(context.Background(), "secret", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "b", string(secret.Data["config.yaml"]))
+	assert.Equal(t, testProvenanceJSON, secret.Annotations[ProvenanceAnnotation])
+}
+
+var (
+	testProvenance     = []Provenance{{Source: "git /repo@main", Version: "abc"}}
+	testProvenanceJSON = `[{"source":"git /repo@main","version":"abc"}]`
+)
+
+func Test_configMapSink_conflict(t *testing.T) {
+	// A manually emptied ConfigMap has no data.
+	k8sCli := k8sfake.NewSimpleClientset(&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "cm"}})
+	conflicts := 0
+	k8sCli.PrependReactor("update", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if conflicts < 2 {
+			conflicts++
+			return true, nil, apierrors.NewConflict(schema.GroupResource{Resource: "configmaps"}, "cm", fmt.Errorf("the object has been modified"))
+		}
+		return false, nil, nil
+	})
+	s := NewConfigMapSink(k8sCli, "ns", "cm", "config.yaml", 0)
+	changed, err := s.Write(context.Background(), GlobalConfig{}, []byte("a"))
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, 2, conflicts)
+	cm, err := k8sCli.CoreV1().ConfigMaps("ns").Get(context.Background(), "cm", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "a", cm.Data["config.yaml"])
+}
+
+func Test_fileSink(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "config.yaml")
+	s := NewFileSink(p)
+	changed, err := s.Write(context.Background(), GlobalConfig{}, []byte("a"))
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	changed, err = s.Write(context.Background(), GlobalConfig{}, []byte("a"))
+	assert.NoError(t, err)
+	assert.False(t, changed)
+	content, err := os.ReadFile(p)
+	assert.NoError(t, err)
+	assert.Equal(t, "a", string(content))
+	entries, err := os.ReadDir(filepath.Dir(p))
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	// The provenance is written next to the config, and removed if there's none.
+	_, err = s.Write(context.Background(), GlobalConfig{Provenance: testProvenance}, []byte("b"))
+	assert.NoError(t, err)
+	content, err = os.ReadFile(p + ProvenanceFileSuffix)
+	assert.NoError(t, err)
+	assert.Equal(t, testProvenanceJSON, string(content))
+	_, err = s.Write(context.Background(), GlobalConfig{}, []byte("c"))
+	assert.NoError(t, err)
+	assert.NoFileExists(t, p+ProvenanceFileSuffix)
+}
+
+func Test_httpSink(t *testing.T) {
+	var bodies, provenances []string
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "token", r.Header.Get("Authorization"))
+		b, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+		provenances = append(provenances, r.Header.Get(ProvenanceHeader))
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+	s := NewHTTPSink(server.URL, map[string]string{"Authorization": "token"}, time.Second)
+	changed, err := s.Write(context.Background(), GlobalConfig{}, []byte("a"))
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	changed, err = s.Write(context.Background(), GlobalConfig{}, []byte("a"))
+	assert.NoError(t, err)
+	assert.False(t, changed)
+	status = http.StatusInternalServerError
+	_, err = s.Write(context.Background(), GlobalConfig{}, []byte("b"))
+	assert.Error(t, err)
+	status = http.StatusOK
+	changed, err = s.Write(context.Background(), GlobalConfig{Provenance: testProvenance}, []byte("b"))
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, []string{"a", "b", "b"}, bodies)
+	assert.Equal(t, []string{"", "", testProvenanceJSON}, provenances)
+}
+
+func Test_runOnce_sinks(t *testing.T) {
+	k8sCli := k8sfake.NewSimpleClientset()
+	_, err := k8sCli.CoreV1().ConfigMaps("ns1").Create(context.Background(), fakeAppConfigMap(t, "ns1", "n1"), metav1.CreateOptions{})
+	assert.NoError(t, err)
+	path, err := os.Getwd()
+	assert.NoError(t, err)
+	p := filepath.Join(t.TempDir(), "config.yaml")
+	failing := NewHTTPSink("http://127.0.0.1:0", nil, time.Second)
+	a := NewAggregator(k8sCli, "test-ns", "test-cm", WithName("test-sinks"), WithSchemaFileDir(path+"/../../manifests/install/base"), WithSinks(failing, NewFileSink(p)))
+	// The failing sink does not block the others.
+	assert.Error(t, a.runOnce(context.Background()))
+	cm, err := k8sCli.CoreV1().ConfigMaps("test-ns").Get(context.Background(), "test-cm", metav1.GetOptions{})
+	assert.NoError(t, err)
+	content, err := os.ReadFile(p)
+	assert.NoError(t, err)
+	assert.Equal(t, cm.Data[defaultSettings.configMapKey], string(content))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.SinkWritesFailedTotal.WithLabelValues("test-sinks", failing.String())))
+}
@@ -0,0 +1,158 @@
+package aggregator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	KindConfigMap          = "ConfigMap"
+	KindNumalogicAppConfig = "NumalogicAppConfig"
+)
+
+// Source is a backend of the application configs, e.g. the labeled ConfigMaps.
+type Source interface {
+	// String returns the name of the source, used in the logs.
+	String() string
+	// Start starts watching the backend, notify is called on the changes. A source without the change
+	// notifications does nothing, it's read in the periodical runs.
+	Start(ctx context.Context, notify func()) error
+	// List lists the application configs in the backend.
+	List(ctx context.Context) ([]SourceConfig, error)
+}
+
+// VersionedSource is a Source with the versions, e.g. the commits of a git repository, the versions are
+// recorded in the provenance of the aggregated config.
+type VersionedSource interface {
+	Source
+	// Version returns the version read by the last List.
+	Version() string
+}
+
+// SourceConfig is a set of the application configs read from a Source, e.g. a ConfigMap.
+type SourceConfig struct {
+	// Kind of the config, e.g. ConfigMap, used in the logs and the errors
+	Kind      string
+	Namespace string
+	Name      string
+	// Annotations of the config, e.g. the precedence
+	Annotations map[string]string
+	// The config entries, e.g. the data of a ConfigMap
+	Data map[string]string
+	// Errors of reading the config, e.g. an invalid NumalogicAppConfig spec
+	Errors []string
+	// The object of the config, the events and the status are recorded to it if it's a ConfigMap or a NumalogicAppConfig
+	Object runtime.Object
+}
+
+// An application config source, e.g. a ConfigMap, and the result of aggregating it.
+type appSource struct {
+	// The object of the source, used to record the events and the status
+	object      runtime.Object
+	kind        string
+	namespace   string
+	name        string
+	annotations map[string]string
+	// The config entries of the source, e.g. the data of a ConfigMap
+	data map[string]string
+
+	// Errors of the invalid or conflicting entries
+	errors []string
+	// Keys of the entries with empty config
+	emptyKeys []string
+	// The configs accepted into the aggregation
+	accepted []obj
+	// Services of the namespace kept from their last valid versions while the source is invalid
+	keptServices []string
+}
+
+func newAppSource(c SourceConfig) *appSource {
+	return &appSource{
+		object:      c.Object,
+		kind:        c.Kind,
+		namespace:   c.Namespace,
+		name:        c.Name,
+		annotations: c.Annotations,
+		data:        c.Data,
+		errors:      c.Errors,
+	}
+}
+
+func newConfigMapSource(cm *corev1.ConfigMap) *appSource {
+	return newAppSource(configMapSourceConfig(cm))
+}
+
+func configMapSourceConfig(cm *corev1.ConfigMap) SourceConfig {
+	return SourceConfig{
+		Kind:        KindConfigMap,
+		Namespace:   cm.Namespace,
+		Name:        cm.Name,
+		Annotations: cm.Annotations,
+		Data:        cm.Data,
+		Object:      cm,
+	}
+}
+
+// The labeled application ConfigMaps.
+type appConfigMapSource struct {
+	a *aggregator
+}
+
+func (s *appConfigMapSource) String() string {
+	return "ConfigMaps"
+}
+
+func (s *appConfigMapSource) Start(ctx context.Context, notify func()) error {
+	return s.a.startConfigMapInformer(ctx, notify)
+}
+
+func (s *appConfigMapSource) List(ctx context.Context) ([]SourceConfig, error) {
+	cms, err := s.a.listAppConfigMaps(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list configmaps, %w", err)
+	}
+	result := make([]SourceConfig, 0, len(cms))
+	for i := range cms {
+		result = append(result, configMapSourceConfig(&cms[i]))
+	}
+	return result, nil
+}
+
+func (s *appSource) String() string {
+	return fmt.Sprintf("%s %q", s.kind, s.name)
+}
+
+func (s *appSource) logFields() []interface{} {
+	return []interface{}{zap.String("namespace", s.namespace), zap.String(strings.ToLower(s.kind), s.name)}
+}
+
+// Poll the version of a source without the change notifications, e.g. a fingerprint of the files, and notify
+// when it changes. It fails if the initial version can't be read, the later errors are left to the next List.
+func pollSource(ctx context.Context, interval time.Duration, version func(context.Context) (string, error), notify func()) error {
+	last, err := version(ctx)
+	if err != nil {
+		return err
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if v, err := version(ctx); err == nil && v != last {
+					last = v
+					notify()
+				}
+			}
+		}
+	}()
+	return nil
+}
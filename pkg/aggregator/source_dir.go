@@ -0,0 +1,122 @@
+package aggregator
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// KindFile is the kind of the application configs read from the local files.
+const KindFile = "File"
+
+// How often a directory source checks the changes of the files.
+var dirSourcePollInterval = 2 * time.Second
+
+type dirSource struct {
+	dir string
+}
+
+// NewDirSource returns a source reading the application configs from a local directory, it's meant for
+// the local development without a cluster. Each sub-directory is a namespace, and each .yaml, .yml or
+// .json file in it is an application config, in the same format as an entry of an application ConfigMap,
+// e.g. "<dir>/<namespace>/<service>.yaml". The other files are ignored.
+func NewDirSource(dir string) Source {
+	return &dirSource{dir: dir}
+}
+
+func (s *dirSource) String() string {
+	return fmt.Sprintf("directory %s", s.dir)
+}
+
+// Start polls the files, since they are not watched by the informers.
+func (s *dirSource) Start(ctx context.Context, notify func()) error {
+	return pollSource(ctx, dirSourcePollInterval, func(context.Context) (string, error) {
+		return s.fingerprint()
+	}, notify)
+}
+
+func (s *dirSource) List(_ context.Context) ([]SourceConfig, error) {
+	files, err := s.files()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]SourceConfig, 0, len(files))
+	for _, f := range files {
+		b, err := os.ReadFile(filepath.Join(s.dir, f.namespace, f.name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s, %w", filepath.Join(f.namespace, f.name), err)
+		}
+		result = append(result, SourceConfig{
+			Kind:      KindFile,
+			Namespace: f.namespace,
+			Name:      f.name,
+			Data:      map[string]string{f.name: string(b)},
+		})
+	}
+	return result, nil
+}
+
+type dirSourceFile struct {
+	namespace string
+	name      string
+	info      os.FileInfo
+}
+
+// The application config files, ordered by the namespace and the name.
+func (s *dirSource) files() ([]dirSourceFile, error) {
+	namespaces, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory %s, %w", s.dir, err)
+	}
+	var result []dirSourceFile
+	for _, ns := range namespaces {
+		if !ns.IsDir() || strings.HasPrefix(ns.Name(), ".") {
+			continue
+		}
+		entries, err := os.ReadDir(filepath.Join(s.dir, ns.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read directory %s, %w", filepath.Join(s.dir, ns.Name()), err)
+		}
+		for _, e := range entries {
+			switch filepath.Ext(e.Name()) {
+			case ".yaml", ".yml", ".json":
+			default:
+				continue
+			}
+			if e.IsDir() {
+				continue
+			}
+			info, err := e.Info()
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s, %w", filepath.Join(ns.Name(), e.Name()), err)
+			}
+			result = append(result, dirSourceFile{namespace: ns.Name(), name: e.Name(), info: info})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].namespace != result[j].namespace {
+			return result[i].namespace < result[j].namespace
+		}
+		return result[i].name < result[j].name
+	})
+	return result, nil
+}
+
+// The fingerprint of the names, sizes and modification times of the files.
+func (s *dirSource) fingerprint() (string, error) {
+	files, err := s.files()
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	for _, f := range files {
+		fmt.Fprintf(h, "%s/%s %d %d\n", f.namespace, f.name, f.info.Size(), f.info.ModTime().UnixNano())
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,76 @@
+package aggregator
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/yaml"
+)
+
+func writeDirSourceFile(t *testing.T, dir, namespace, name, content string) {
+	t.Helper()
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, namespace), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, namespace, name), []byte(content), 0644))
+}
+
+func Test_dirSource(t *testing.T) {
+	dir := t.TempDir()
+	writeDirSourceFile(t, dir, "ns2", "test.yaml", applicationConfigStr)
+	writeDirSourceFile(t, dir, "ns1", "test.yaml", applicationConfigStr)
+	writeDirSourceFile(t, dir, "ns1", "README.md", "hello")
+	writeDirSourceFile(t, dir, ".git", "config.yaml", "hello")
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "top.yaml"), []byte("hello"), 0644))
+
+	s := NewDirSource(dir)
+	configs, err := s.List(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, configs, 2)
+	assert.Equal(t, SourceConfig{Kind: KindFile, Namespace: "ns1", Name: "test.yaml", Data: map[string]string{"test.yaml": applicationConfigStr}}, configs[0])
+	assert.Equal(t, "ns2", configs[1].Namespace)
+
+	_, err = NewDirSource(filepath.Join(dir, "missing")).List(context.Background())
+	assert.Error(t, err)
+}
+
+func Test_dirSource_Start(t *testing.T) {
+	defer func(d time.Duration) { dirSourcePollInterval = d }(dirSourcePollInterval)
+	dirSourcePollInterval = 10 * time.Millisecond
+	dir := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	notified := make(chan struct{}, 1)
+	assert.NoError(t, NewDirSource(dir).Start(ctx, func() {
+		select {
+		case notified <- struct{}{}:
+		default:
+		}
+	}))
+	writeDirSourceFile(t, dir, "ns1", "test.yaml", applicationConfigStr)
+	select {
+	case <-notified:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no notification of the new file")
+	}
+}
+
+func Test_runOnce_withoutCluster(t *testing.T) {
+	dir := t.TempDir()
+	writeDirSourceFile(t, dir, "ns1", "test.yaml", applicationConfigStr)
+	writeDirSourceFile(t, dir, "ns2", "test.yaml", "invalid")
+	p := filepath.Join(t.TempDir(), "config.yaml")
+	path, err := os.Getwd()
+	assert.NoError(t, err)
+	a := NewAggregator(nil, "", "test-cm", WithConfigMapSource(false), WithSources(NewDirSource(dir)), WithSinks(NewFileSink(p)), WithSchemaFileDir(path+"/../../manifests/install/base"))
+	assert.NoError(t, a.runOnce(context.Background()))
+	content, err := os.ReadFile(p)
+	assert.NoError(t, err)
+	var c GlobalConfig
+	assert.NoError(t, yaml.Unmarshal(content, &c))
+	assert.Len(t, c.Configs, 1)
+	assert.Equal(t, "ns1", c.Configs[0][Namespace])
+	assert.Equal(t, "test", c.Configs[0]["service"])
+}
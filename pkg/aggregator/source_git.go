@@ -0,0 +1,128 @@
+package aggregator
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// KindGitFile is the kind of the application configs read from a git repository.
+	KindGitFile = "GitFile"
+	// DefaultGitPathPattern is the default pattern of the application config files in a git repository.
+	DefaultGitPathPattern = "*/*.yaml"
+)
+
+// How often a git source checks the changes of the ref.
+var gitSourcePollInterval = 10 * time.Second
+
+type gitSource struct {
+	repo    string
+	ref     string
+	pattern string
+
+	lock sync.RWMutex
+	// The commit read by the last List
+	commit string
+}
+
+// NewGitSource returns a source reading the application configs from a local git checkout or bare
+// repository at a ref, e.g. a branch, a tag or a commit, it defaults to HEAD. Each file matching the
+// path pattern (in the syntax of path.Match) is an application config, in the same format as an entry
+// of an application ConfigMap, and the name of its directory is the namespace, e.g. "apps/<namespace>/<service>.yaml"
+// with the pattern "apps/*/*.yaml". The files are read from the commit, the working tree is ignored, and
+// the repository is kept up to date externally, e.g. by a git-sync sidecar. It requires the git binary.
+func NewGitSource(repo, ref, pattern string) Source {
+	if ref == "" {
+		ref = "HEAD"
+	}
+	if pattern == "" {
+		pattern = DefaultGitPathPattern
+	}
+	return &gitSource{repo: repo, ref: ref, pattern: pattern}
+}
+
+func (s *gitSource) String() string {
+	return fmt.Sprintf("git %s@%s", s.repo, s.ref)
+}
+
+// Version returns the commit SHA read by the last List.
+func (s *gitSource) Version() string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.commit
+}
+
+// Start polls the ref for the new commits.
+func (s *gitSource) Start(ctx context.Context, notify func()) error {
+	return pollSource(ctx, gitSourcePollInterval, s.resolve, notify)
+}
+
+func (s *gitSource) List(ctx context.Context) ([]SourceConfig, error) {
+	commit, err := s.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out, err := s.git(ctx, "ls-tree", "-r", "-z", "--name-only", commit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the files of commit %s, %w", commit, err)
+	}
+	result := []SourceConfig{}
+	for _, p := range strings.Split(string(out), "\x00") {
+		if p == "" {
+			continue
+		}
+		if matched, err := path.Match(s.pattern, p); err != nil {
+			return nil, fmt.Errorf("invalid path pattern %q, %w", s.pattern, err)
+		} else if !matched {
+			continue
+		}
+		dir := path.Dir(p)
+		if dir == "." {
+			continue
+		}
+		content, err := s.git(ctx, "cat-file", "blob", commit+":"+p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s of commit %s, %w", p, commit, err)
+		}
+		result = append(result, SourceConfig{
+			Kind:      KindGitFile,
+			Namespace: path.Base(dir),
+			Name:      p,
+			Data:      map[string]string{path.Base(p): string(content)},
+		})
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.commit = commit
+	return result, nil
+}
+
+// Resolve the ref to a commit SHA.
+func (s *gitSource) resolve(ctx context.Context) (string, error) {
+	out, err := s.git(ctx, "rev-parse", "--verify", "--quiet", s.ref+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve ref %q of git repository %s, %w", s.ref, s.repo, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func (s *gitSource) git(ctx context.Context, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	// The repository may be owned by another user, e.g. a git-sync sidecar.
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-c", "safe.directory=" + s.repo, "-C", s.repo}, args...)...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,128 @@
+package aggregator
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/yaml"
+)
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@test"}, args...)...)
+	out, err := cmd.CombinedOutput()
+	assert.NoError(t, err, string(out))
+	return strings.TrimSpace(string(out))
+}
+
+func fakeGitRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+	dir := t.TempDir()
+	runGit(t, dir, "init", "-q", "-b", "main")
+	writeDirSourceFile(t, dir, "apps/ns1", "test.yaml", applicationConfigStr)
+	writeDirSourceFile(t, dir, "apps/ns2", "test.yaml", applicationConfigStr)
+	writeDirSourceFile(t, dir, "apps/ns2", "README.md", "hello")
+	writeDirSourceFile(t, dir, "other", "test.yaml", "hello")
+	runGit(t, dir, "add", ".")
+	runGit(t, dir, "commit", "-q", "-m", "init")
+	return dir
+}
+
+func Test_gitSource(t *testing.T) {
+	dir := fakeGitRepo(t)
+	first := runGit(t, dir, "rev-parse", "HEAD")
+	assert.NoError(t, os.Remove(filepath.Join(dir, "apps", "ns2", "test.yaml")))
+	runGit(t, dir, "commit", "-q", "-a", "-m", "remove ns2")
+	second := runGit(t, dir, "rev-parse", "HEAD")
+	// The working tree is ignored.
+	writeDirSourceFile(t, dir, "apps/ns3", "test.yaml", applicationConfigStr)
+
+	s := NewGitSource(dir, "", "apps/*/*.yaml")
+	configs, err := s.List(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []SourceConfig{{Kind: KindGitFile, Namespace: "ns1", Name: "apps/ns1/test.yaml", Data: map[string]string{"test.yaml": applicationConfigStr}}}, configs)
+	assert.Equal(t, second, s.(VersionedSource).Version())
+
+	s = NewGitSource(dir, first, "apps/*/*.yaml")
+	configs, err = s.List(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, configs, 2)
+	assert.Equal(t, "ns2", configs[1].Namespace)
+	assert.Equal(t, first, s.(VersionedSource).Version())
+
+	_, err = NewGitSource(dir, "missing", "").List(context.Background())
+	assert.Error(t, err)
+
+	// A bare repository.
+	bare := filepath.Join(t.TempDir(), "repo.git")
+	runGit(t, dir, "clone", "-q", "--bare", dir, bare)
+	configs, err = NewGitSource(bare, "main", "apps/*/*.yaml").List(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, configs, 1)
+}
+
+func Test_runOnce_provenance(t *testing.T) {
+	ctx := context.Background()
+	dir := fakeGitRepo(t)
+	commit := runGit(t, dir, "rev-parse", "HEAD")
+	p := filepath.Join(t.TempDir(), "config.yaml")
+	path, err := os.Getwd()
+	assert.NoError(t, err)
+	k8sCli := k8sfake.NewSimpleClientset()
+	s := NewGitSource(dir, "main", "apps/*/*.yaml")
+	a := NewAggregator(k8sCli, "test-ns", "test-cm", WithConfigMapSource(false), WithSources(s), WithSinks(NewFileSink(p)), WithSchemaFileDir(path+"/../../manifests/install/base"))
+	assert.NoError(t, a.runOnce(ctx))
+	content, err := os.ReadFile(p)
+	assert.NoError(t, err)
+	var c GlobalConfig
+	assert.NoError(t, yaml.Unmarshal(content, &c))
+	assert.Len(t, c.Configs, 2)
+	// The provenance is not a part of the content.
+	assert.NotContains(t, string(content), commit)
+	cm, err := k8sCli.CoreV1().ConfigMaps("test-ns").Get(ctx, "test-cm", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, `[{"source":"`+s.String()+`","version":"`+commit+`"}]`, cm.Annotations[ProvenanceAnnotation])
+	c, _, err = a.primarySink.read(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []Provenance{{Source: s.String(), Version: commit}}, c.Provenance)
+
+	// A commit not changing the configs doesn't update the aggregated ConfigMap.
+	writeDirSourceFile(t, dir, "docs", "README.md", "hello")
+	runGit(t, dir, "add", ".")
+	runGit(t, dir, "commit", "-q", "-m", "docs")
+	k8sCli.ClearActions()
+	assert.NoError(t, a.runOnce(ctx))
+	for _, action := range k8sCli.Actions() {
+		assert.NotEqual(t, "update", action.GetVerb())
+		assert.NotEqual(t, "create", action.GetVerb())
+	}
+
+	// A commit changing the configs updates the provenance along with the content.
+	assert.NoError(t, os.Remove(filepath.Join(dir, "apps", "ns2", "test.yaml")))
+	runGit(t, dir, "commit", "-q", "-a", "-m", "remove ns2")
+	assert.NoError(t, a.runOnce(ctx))
+	cm, err = k8sCli.CoreV1().ConfigMaps("test-ns").Get(ctx, "test-cm", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Contains(t, cm.Annotations[ProvenanceAnnotation], runGit(t, dir, "rev-parse", "HEAD"))
+	provenance, err := os.ReadFile(p + ProvenanceFileSuffix)
+	assert.NoError(t, err)
+	assert.Equal(t, cm.Annotations[ProvenanceAnnotation], string(provenance))
+
+	// Without a cluster, the provenance is only written by the sinks.
+	local := filepath.Join(t.TempDir(), "config.yaml")
+	a = NewAggregator(nil, "", "test-cm", WithConfigMapSource(false), WithSources(s), WithSinks(NewFileSink(local)), WithSchemaFileDir(path+"/../../manifests/install/base"))
+	assert.NoError(t, a.runOnce(ctx))
+	provenance, err = os.ReadFile(local + ProvenanceFileSuffix)
+	assert.NoError(t, err)
+	assert.Contains(t, string(provenance), runGit(t, dir, "rev-parse", "HEAD"))
+}
@@ -0,0 +1,129 @@
+package aggregator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/numaproj-labs/numalogic-config-aggregator/pkg/metrics"
+)
+
+// DefaultStaleConfigGracePeriod is the default period to keep the last valid version of an application config.
+const DefaultStaleConfigGracePeriod = 24 * time.Hour
+
+// The last valid version of an application config.
+type knownGoodConfig struct {
+	config obj
+	// When the config became invalid, zero if it's valid
+	staleSince time.Time
+}
+
+func knownGoodKey(c obj) (string, bool) {
+	ns, ok1 := c[Namespace].(string)
+	service, ok2 := c["service"].(string)
+	return ns + "/" + service, ok1 && ok2
+}
+
+// Keep the last valid versions of the application configs missing from the aggregated config, if their
+// namespaces have invalid configs in this run, i.e. they're missing because they become invalid rather
+// than being removed. They're kept as stale until they become valid again, or the grace period expires.
+// The last valid versions are seeded from the aggregated ConfigMap in the first run.
+func (a *aggregator) retainKnownGoodConfigs(ctx context.Context, sources []*appSource, config *GlobalConfig) {
+	if a.staleConfigGracePeriod <= 0 {
+		return
+	}
+	if a.knownGood == nil {
+		a.knownGood = map[string]*knownGoodConfig{}
+		if err := a.seedKnownGoodConfigs(ctx); err != nil {
+			// Retried in the next run, nothing is retained until it's seeded.
+			a.knownGood = nil
+			a.logger.Errorw("Failed to read the last valid configs from the aggregated ConfigMap", zap.Error(err))
+			return
+		}
+	}
+	invalidSources := map[string][]*appSource{}
+	for _, src := range sources {
+		if len(src.errors) > 0 {
+			invalidSources[src.namespace] = append(invalidSources[src.namespace], src)
+		}
+	}
+	current := map[string]bool{}
+	for _, c := range config.Configs {
+		if key, ok := knownGoodKey(c); ok {
+			current[key] = true
+		}
+	}
+	now := time.Now()
+	var keys []string
+	for key := range a.knownGood {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		kg := a.knownGood[key]
+		if current[key] {
+			continue
+		}
+		ns, _ := kg.config[Namespace].(string)
+		if len(invalidSources[ns]) == 0 {
+			// Removed, or moved to another namespace.
+			delete(a.knownGood, key)
+			continue
+		}
+		if kg.staleSince.IsZero() {
+			kg.staleSince = now
+		}
+		if now.Sub(kg.staleSince) > a.staleConfigGracePeriod {
+			a.logger.Warnw("The grace period of the stale application config expired, it's removed from the aggregated config", zap.String("namespace", ns), zap.Any("service", kg.config["service"]), zap.Time("staleSince", kg.staleSince))
+			delete(a.knownGood, key)
+			if a.expiredStale == nil {
+				a.expiredStale = map[string]bool{}
+			}
+			a.expiredStale[key] = true
+			continue
+		}
+		a.logger.Warnw("The application config is invalid, keeping its last valid version", zap.String("namespace", ns), zap.Any("service", kg.config["service"]), zap.Time("staleSince", kg.staleSince))
+		config.Configs = append(config.Configs, kg.config)
+		config.Stale = append(config.Stale, StaleConfig{Namespace: ns, Service: fmt.Sprint(kg.config["service"]), Since: metav1.NewTime(kg.staleSince.UTC().Truncate(time.Second))})
+		for _, src := range invalidSources[ns] {
+			src.keptServices = append(src.keptServices, fmt.Sprint(kg.config["service"]))
+		}
+	}
+	if len(config.Stale) > 0 {
+		// Keep the configs of a namespace together.
+		sort.SliceStable(config.Configs, func(i, j int) bool {
+			return fmt.Sprint(config.Configs[i][Namespace]) < fmt.Sprint(config.Configs[j][Namespace])
+		})
+	}
+	for _, c := range config.Configs {
+		if key, ok := knownGoodKey(c); ok && current[key] {
+			a.knownGood[key] = &knownGoodConfig{config: c}
+		}
+	}
+	metrics.StaleConfigs.WithLabelValues(a.name).Set(float64(len(config.Stale)))
+}
+
+// Seed the last valid versions of the application configs from the aggregated ConfigMap.
+func (a *aggregator) seedKnownGoodConfigs(ctx context.Context) error {
+	if a.primarySink == nil {
+		return nil
+	}
+	config, found, err := a.primarySink.read(ctx)
+	if err != nil || !found {
+		return err
+	}
+	staleSince := map[string]time.Time{}
+	for _, s := range config.Stale {
+		staleSince[s.Namespace+"/"+s.Service] = s.Since.Time
+	}
+	for _, c := range config.Configs {
+		if key, ok := knownGoodKey(c); ok {
+			a.knownGood[key] = &knownGoodConfig{config: c, staleSince: staleSince[key]}
+		}
+	}
+	return nil
+}
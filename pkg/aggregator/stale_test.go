@@ -0,0 +1,156 @@
+package aggregator
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/yaml"
+)
+
+func Test_retainKnownGoodConfigs(t *testing.T) {
+	ctx := context.Background()
+	path, err := os.Getwd()
+	assert.NoError(t, err)
+	// The aggregated ConfigMap before the aggregator starts.
+	seed, err := yaml.Marshal(fakeGlobalConfig(t))
+	assert.NoError(t, err)
+	k8sCli := k8sfake.NewSimpleClientset(&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "test-cm"}, Data: map[string]string{defaultSettings.configMapKey: string(seed)}})
+	invalid := fakeAppConfigMap(t, "ns1", "n1")
+	invalid.Data["hello"] = "service: test\nmetric_configs: invalid\n"
+	_, err = k8sCli.CoreV1().ConfigMaps("ns1").Create(ctx, invalid, metav1.CreateOptions{})
+	assert.NoError(t, err)
+	recorder := record.NewFakeRecorder(10)
+	a := NewAggregator(k8sCli, "test-ns", "test-cm", WithSchemaFileDir(path+"/../../manifests/install/base"), WithStaleConfigGracePeriod(time.Hour), WithEventRecorder(recorder))
+	getConfig := func() GlobalConfig {
+		cm, err := k8sCli.CoreV1().ConfigMaps("test-ns").Get(ctx, "test-cm", metav1.GetOptions{})
+		assert.NoError(t, err)
+		var c GlobalConfig
+		assert.NoError(t, yaml.Unmarshal([]byte(cm.Data[defaultSettings.configMapKey]), &c))
+		return c
+	}
+
+	// The last valid version is seeded from the aggregated ConfigMap.
+	assert.NoError(t, a.runOnce(ctx))
+	c := getConfig()
+	assert.Len(t, c.Configs, 1)
+	assert.Equal(t, "ns1", c.Configs[0][Namespace])
+	assert.Len(t, c.Stale, 1)
+	assert.Equal(t, "test", c.Stale[0].Service)
+	since := c.Stale[0].Since
+	assert.Contains(t, <-recorder.Events, "Invalid application config, the last valid version of service(s) test is kept in the aggregation: ")
+
+	// The stale time is kept after a restart.
+	a = NewAggregator(k8sCli, "test-ns", "test-cm", WithSchemaFileDir(path+"/../../manifests/install/base"), WithStaleConfigGracePeriod(time.Hour), WithEventRecorder(recorder))
+	assert.NoError(t, a.runOnce(ctx))
+	assert.Equal(t, since.Unix(), getConfig().Stale[0].Since.Unix())
+	assert.Contains(t, <-recorder.Events, "the last valid version of service(s) test is kept")
+
+	// It's removed after the grace period.
+	a.knownGood["ns1/test"].staleSince = time.Now().Add(-2 * time.Hour)
+	assert.NoError(t, a.runOnce(ctx))
+	c = getConfig()
+	assert.Empty(t, c.Configs)
+	assert.Empty(t, c.Stale)
+	assert.Contains(t, <-recorder.Events, "Invalid application config dropped from the aggregation: ")
+
+	// A valid version replaces the stale one.
+	_, err = k8sCli.CoreV1().ConfigMaps("ns1").Update(ctx, fakeAppConfigMap(t, "ns1", "n1"), metav1.UpdateOptions{})
+	assert.NoError(t, err)
+	assert.NoError(t, a.runOnce(ctx))
+	assert.Len(t, getConfig().Configs, 1)
+	_, err = k8sCli.CoreV1().ConfigMaps("ns1").Update(ctx, invalid, metav1.UpdateOptions{})
+	assert.NoError(t, err)
+	assert.NoError(t, a.runOnce(ctx))
+	c = getConfig()
+	assert.Len(t, c.Configs, 1)
+	assert.Len(t, c.Stale, 1)
+
+	// A removed config is not kept.
+	assert.NoError(t, k8sCli.CoreV1().ConfigMaps("ns1").Delete(ctx, "n1", metav1.DeleteOptions{}))
+	assert.NoError(t, a.runOnce(ctx))
+	c = getConfig()
+	assert.Empty(t, c.Configs)
+	assert.Empty(t, c.Stale)
+}
+
+func Test_retainKnownGoodConfigs_seedFailure(t *testing.T) {
+	ctx := context.Background()
+	path, err := os.Getwd()
+	assert.NoError(t, err)
+	k8sCli := k8sfake.NewSimpleClientset(&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "test-cm"}, Data: map[string]string{defaultSettings.configMapKey: "configs: [oops"}})
+	_, err = k8sCli.CoreV1().ConfigMaps("ns1").Create(ctx, fakeAppConfigMap(t, "ns1", "n1"), metav1.CreateOptions{})
+	assert.NoError(t, err)
+	a := NewAggregator(k8sCli, "test-ns", "test-cm", WithSchemaFileDir(path+"/../../manifests/install/base"), WithStaleConfigGracePeriod(time.Hour))
+	assert.NotPanics(t, func() { _ = a.runOnce(ctx) })
+	assert.Nil(t, a.knownGood)
+
+	// It's seeded in a later run, the run above repaired the aggregated ConfigMap.
+	cm, err := k8sCli.CoreV1().ConfigMaps("test-ns").Get(ctx, "test-cm", metav1.GetOptions{})
+	assert.NoError(t, err)
+	cm.Data[defaultSettings.configMapKey] = "configs: [oops"
+	cm, err = k8sCli.CoreV1().ConfigMaps("test-ns").Update(ctx, cm, metav1.UpdateOptions{})
+	assert.NoError(t, err)
+	sources, err := a.listSources(ctx)
+	assert.NoError(t, err)
+	config := a.aggregate(sources)
+	a.retainKnownGoodConfigs(ctx, sources, &config)
+	assert.Nil(t, a.knownGood)
+	cm.Data[defaultSettings.configMapKey] = "configs: []\n"
+	_, err = k8sCli.CoreV1().ConfigMaps("test-ns").Update(ctx, cm, metav1.UpdateOptions{})
+	assert.NoError(t, err)
+	a.retainKnownGoodConfigs(ctx, sources, &config)
+	assert.Len(t, a.knownGood, 1)
+	assert.Len(t, config.Configs, 1)
+}
+
+func Test_retainKnownGoodConfigs_removalGuard(t *testing.T) {
+	ctx := context.Background()
+	path, err := os.Getwd()
+	assert.NoError(t, err)
+	seed := fakeGlobalConfig(t)
+	other := fakeApplicationConfig(t)
+	other[Namespace] = "ns1"
+	other["service"] = "test2"
+	seed.Configs = append(seed.Configs, other)
+	seedBytes, err := yaml.Marshal(seed)
+	assert.NoError(t, err)
+	k8sCli := k8sfake.NewSimpleClientset(&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "test-cm"}, Data: map[string]string{defaultSettings.configMapKey: string(seedBytes)}})
+	invalid := fakeAppConfigMap(t, "ns1", "n1")
+	invalid.Data["hello"] = "service: test\nmetric_configs: invalid\n"
+	_, err = k8sCli.CoreV1().ConfigMaps("ns1").Create(ctx, invalid, metav1.CreateOptions{})
+	assert.NoError(t, err)
+	// The removal guard is on by default.
+	a := NewAggregator(k8sCli, "test-ns", "test-cm", WithSchemaFileDir(path+"/../../manifests/install/base"), WithStaleConfigGracePeriod(time.Hour))
+	assert.NoError(t, a.runOnce(ctx))
+	assert.Len(t, a.knownGood, 2)
+
+	// All the published configs expire, they're not blocked as removals.
+	for _, kg := range a.knownGood {
+		kg.staleSince = time.Now().Add(-2 * time.Hour)
+	}
+	assert.NoError(t, a.runOnce(ctx))
+	cm, err := k8sCli.CoreV1().ConfigMaps("test-ns").Get(ctx, "test-cm", metav1.GetOptions{})
+	assert.NoError(t, err)
+	var c GlobalConfig
+	assert.NoError(t, yaml.Unmarshal([]byte(cm.Data[defaultSettings.configMapKey]), &c))
+	assert.Empty(t, c.Configs)
+	assert.Empty(t, c.Stale)
+
+	// Removing all the valid configs is still blocked.
+	for _, ns := range []string{"ns2", "ns3"} {
+		_, err := k8sCli.CoreV1().ConfigMaps(ns).Create(ctx, fakeAppConfigMap(t, ns, "n1"), metav1.CreateOptions{})
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, a.runOnce(ctx))
+	for _, ns := range []string{"ns2", "ns3"} {
+		assert.NoError(t, k8sCli.CoreV1().ConfigMaps(ns).Delete(ctx, "n1", metav1.DeleteOptions{}))
+	}
+	assert.Error(t, a.runOnce(ctx))
+}
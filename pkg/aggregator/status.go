@@ -0,0 +1,116 @@
+package aggregator
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/yaml"
+
+	"github.com/numaproj-labs/numalogic-config-aggregator/pkg/apis/numalogic/v1alpha1"
+)
+
+func (s *appSource) status() string {
+	switch {
+	case len(s.errors) > 0:
+		return StatusInvalid
+	case len(s.accepted) == 0:
+		return StatusEmpty
+	default:
+		return StatusValid
+	}
+}
+
+// The hash of the configs accepted into the aggregation.
+func (s *appSource) hash() (string, error) {
+	for _, c := range s.accepted {
+		canonicalize(c)
+	}
+	b, err := yaml.Marshal(s.accepted)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:]), nil
+}
+
+// Update the status of the application config sources with the aggregation result, a source
+// only gets updated when its result changes.
+func (a *aggregator) updateSourceStatuses(ctx context.Context, sources []*appSource) {
+	for _, src := range sources {
+		var err error
+		switch o := src.object.(type) {
+		case *corev1.ConfigMap:
+			if a.statusAnnotations {
+				err = a.updateConfigMapStatus(ctx, src, o)
+			}
+		case *v1alpha1.NumalogicAppConfig:
+			err = a.updateAppConfigResourceStatus(ctx, src, o)
+		}
+		if err != nil {
+			a.logger.Errorw("Failed to update the status of the application config", append(src.logFields(), zap.Error(err))...)
+		}
+	}
+}
+
+// Whether the current configs of the source were already in the aggregation, according to its status.
+func (s *appSource) alreadyAggregated(hash string) bool {
+	switch o := s.object.(type) {
+	case *corev1.ConfigMap:
+		return o.Annotations[AggregatedHashAnnotation] == hash
+	case *v1alpha1.NumalogicAppConfig:
+		return o.Status.ObservedGeneration == o.Generation && meta.IsStatusConditionTrue(o.Status.Conditions, v1alpha1.ConditionAggregated)
+	default:
+		return false
+	}
+}
+
+// Annotate the application ConfigMap with the aggregation result.
+func (a *aggregator) updateConfigMapStatus(ctx context.Context, src *appSource, cm *corev1.ConfigMap) error {
+	hash, err := src.hash()
+	if err != nil {
+		return fmt.Errorf("failed to calculate the hash of the application config, %w", err)
+	}
+	errs := ""
+	if len(src.errors) > 0 {
+		b, err := json.Marshal(src.errors)
+		if err != nil {
+			return fmt.Errorf("failed to marshal the errors of the application config, %w", err)
+		}
+		errs = string(b)
+	}
+	status := src.status()
+	if cm.Annotations[StatusAnnotation] == status && cm.Annotations[AggregatedHashAnnotation] == hash && cm.Annotations[ErrorsAnnotation] == errs {
+		return nil
+	}
+	annotations := map[string]interface{}{
+		StatusAnnotation:             status,
+		ErrorsAnnotation:             nil, // Removes the annotation
+		LastAggregatedTimeAnnotation: time.Now().UTC().Format(time.RFC3339),
+		AggregatedHashAnnotation:     hash,
+	}
+	if errs != "" {
+		annotations[ErrorsAnnotation] = errs
+	}
+	patch := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": annotations,
+		},
+	}
+	patchBytes, err := json.Marshal(patch)
+	if err != nil {
+		return fmt.Errorf("failed to marshal the status patch, %w", err)
+	}
+	if _, err := a.k8sclient.CoreV1().ConfigMaps(cm.Namespace).Patch(ctx, cm.Name, types.MergePatchType, patchBytes, metav1.PatchOptions{}); err != nil {
+		return fmt.Errorf("failed to patch configmap, %w", err)
+	}
+	return nil
+}
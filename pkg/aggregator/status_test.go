@@ -0,0 +1,52 @@
+package aggregator
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_updateSourceStatuses(t *testing.T) {
+	k8sCli := k8sfake.NewSimpleClientset()
+	valid := fakeAppConfigMap(t, "ns1", "valid")
+	invalid := fakeAppConfigMap(t, "ns2", "invalid")
+	invalid.Data["bad"] = "service: [oops"
+	empty := fakeAppConfigMap(t, "ns3", "empty")
+	empty.Data = map[string]string{"empty": "{}"}
+	_, _ = k8sCli.CoreV1().ConfigMaps("ns1").Create(context.Background(), valid, metav1.CreateOptions{})
+	_, _ = k8sCli.CoreV1().ConfigMaps("ns2").Create(context.Background(), invalid, metav1.CreateOptions{})
+	_, _ = k8sCli.CoreV1().ConfigMaps("ns3").Create(context.Background(), empty, metav1.CreateOptions{})
+	path, err := os.Getwd()
+	assert.NoError(t, err)
+	a := NewAggregator(k8sCli, "test-ns", "test-cm", WithSchemaFileDir(path+"/../../manifests/install/base"))
+	assert.NoError(t, a.runOnce(context.Background()))
+
+	cm, err := k8sCli.CoreV1().ConfigMaps("ns1").Get(context.Background(), "valid", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, StatusValid, cm.Annotations[StatusAnnotation])
+	assert.NotEmpty(t, cm.Annotations[AggregatedHashAnnotation])
+	assert.NotEmpty(t, cm.Annotations[LastAggregatedTimeAnnotation])
+	_, existing := cm.Annotations[ErrorsAnnotation]
+	assert.False(t, existing)
+
+	cm, err = k8sCli.CoreV1().ConfigMaps("ns2").Get(context.Background(), "invalid", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, StatusInvalid, cm.Annotations[StatusAnnotation])
+	assert.Contains(t, cm.Annotations[ErrorsAnnotation], "bad: ")
+	assert.NotEmpty(t, cm.Annotations[AggregatedHashAnnotation])
+
+	cm, err = k8sCli.CoreV1().ConfigMaps("ns3").Get(context.Background(), "empty", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, StatusEmpty, cm.Annotations[StatusAnnotation])
+
+	// No patches if nothing changes
+	k8sCli.ClearActions()
+	assert.NoError(t, a.runOnce(context.Background()))
+	for _, action := range k8sCli.Actions() {
+		assert.NotEqual(t, "patch", action.GetVerb())
+	}
+}
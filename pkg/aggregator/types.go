@@ -1,7 +1,60 @@
 package aggregator
 
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
 const (
 	Namespace = "namespace"
+
+	// DefaultConfigMapKey is the default key of the config in the aggregated ConfigMap.
+	DefaultConfigMapKey = "config.yaml"
+	// ShardIndexKey is the key of the ShardIndex in the aggregated ConfigMap when it's sharded.
+	ShardIndexKey = "index.yaml"
+	// ShardOfLabel is the label on the shards of an aggregated ConfigMap, the value is the name of it.
+	ShardOfLabel = "numalogic.numaproj.io/shard-of"
+
+	// RevisionOfLabel is the label on the revisions of an aggregated ConfigMap, the value is the name of it.
+	RevisionOfLabel = "numalogic.numaproj.io/revision-of"
+	// RevisionAnnotation is the annotation on a revision showing its number.
+	RevisionAnnotation = "numalogic.numaproj.io/revision"
+	// RevisionTimeAnnotation is the annotation on a revision showing when it's created.
+	RevisionTimeAnnotation = "numalogic.numaproj.io/revision-time"
+	// RevisionHashAnnotation is the annotation on a revision showing the sha256 hash of its config.
+	RevisionHashAnnotation = "numalogic.numaproj.io/revision-hash"
+	// ChangedSourcesAnnotation is the annotation on a revision listing the changed application config sources in JSON.
+	ChangedSourcesAnnotation = "numalogic.numaproj.io/changed-sources"
+	// PinnedRevisionAnnotation is the annotation on an aggregated ConfigMap pinning it to a revision.
+	PinnedRevisionAnnotation = "numalogic.numaproj.io/pinned-revision"
+	// ProvenanceAnnotation is the annotation on an aggregated ConfigMap listing the versions of the sources
+	// its config is read from in JSON.
+	ProvenanceAnnotation = "numalogic.numaproj.io/provenance"
+	// ProvenanceHeader is the header of the requests of an HTTP sink with the provenance in JSON.
+	ProvenanceHeader = "X-Numalogic-Provenance"
+	// ProvenanceFileSuffix is the suffix of the file written next to the output of a file sink with the provenance in JSON.
+	ProvenanceFileSuffix = ".provenance.json"
+	// AllowMassRemovalAnnotation is the annotation on an aggregated ConfigMap allowing the next run to remove
+	// more application configs than the limits.
+	AllowMassRemovalAnnotation = "numalogic.numaproj.io/allow-mass-removal"
+
+	// MergeStrategyAnnotation is the annotation on the application ConfigMap to choose how to merge multiple entries.
+	MergeStrategyAnnotation = "numalogic.numaproj.io/merge-strategy"
+	// PriorityAnnotation is the annotation on the application ConfigMap to decide the precedence
+	// when merging ConfigMaps in one namespace, a higher value has a higher precedence.
+	PriorityAnnotation = "numalogic.numaproj.io/priority"
+
+	// StatusAnnotation is the annotation on the application ConfigMap showing the validation status.
+	StatusAnnotation = "numalogic.numaproj.io/status"
+	// ErrorsAnnotation is the annotation on the application ConfigMap listing the validation errors in JSON.
+	ErrorsAnnotation = "numalogic.numaproj.io/errors"
+	// LastAggregatedTimeAnnotation is the annotation on the application ConfigMap showing the last time its result changed.
+	LastAggregatedTimeAnnotation = "numalogic.numaproj.io/last-aggregated-time"
+	// AggregatedHashAnnotation is the annotation on the application ConfigMap showing the hash of its configs in the aggregation.
+	AggregatedHashAnnotation = "numalogic.numaproj.io/aggregated-hash"
+
+	StatusValid   = "Valid"
+	StatusInvalid = "Invalid"
+	StatusEmpty   = "Empty"
 )
 
 type obj = map[string]interface{}
@@ -9,4 +62,46 @@ type obj = map[string]interface{}
 // GlobalConfig describe the global configuration in the centralized namespace
 type GlobalConfig struct {
 	Configs []obj `json:"configs"`
+	// The versions of the sources the configs are read from, e.g. the commit of a git repository. It's not a part
+	// of the content, so a new version not changing the configs doesn't update the sinks.
+	Provenance []Provenance `json:"-"`
+	// The configs kept from the last valid versions, since the current versions are invalid
+	Stale []StaleConfig `json:"stale,omitempty"`
+}
+
+// StaleConfig is an application config kept from its last valid version in the aggregated config.
+type StaleConfig struct {
+	Namespace string `json:"namespace"`
+	Service   string `json:"service"`
+	// When the config became invalid
+	Since metav1.Time `json:"since"`
+}
+
+// Provenance is the version of a source the aggregated config is read from.
+type Provenance struct {
+	// Name of the source
+	Source string `json:"source"`
+	// Version of the source, e.g. a commit SHA
+	Version string `json:"version"`
+}
+
+// ShardIndex is the index manifest in the aggregated ConfigMap when the aggregated config is too large for one
+// ConfigMap, and is split into the shards. Each shard is a ConfigMap with a GlobalConfig of a part of the configs.
+type ShardIndex struct {
+	// The shards in order, concatenating their configs gives the whole aggregated config
+	Shards []Shard `json:"shards"`
+	// The sha256 hash of the whole aggregated config
+	Hash string `json:"hash"`
+	// The stale configs in the aggregated config
+	Stale []StaleConfig `json:"stale,omitempty"`
+}
+
+// Shard is a part of the aggregated config.
+type Shard struct {
+	// Name of the ConfigMap, in the namespace of the aggregated ConfigMap
+	ConfigMap string `json:"configMap"`
+	// Key of the config in the ConfigMap
+	Key string `json:"key"`
+	// Number of the configs in the shard
+	Configs int `json:"configs"`
 }
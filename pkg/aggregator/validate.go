@@ -0,0 +1,53 @@
+package aggregator
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// The max duration to get the namespace of a ConfigMap to validate, the admission request times out in 10s by default.
+const namespaceLookupTimeout = 3 * time.Second
+
+// ValidateConfigMap validates an application ConfigMap the same way as it's aggregated, it returns
+// whether the ConfigMap is a source of the aggregation, and the errors of the invalid entries.
+func (a *aggregator) ValidateConfigMap(ctx context.Context, cm *corev1.ConfigMap) (bool, []string) {
+	if !a.configMapSource || !a.namespaceIncluded(cm.Namespace) {
+		return false, nil
+	}
+	selector, err := labels.Parse(a.appConfigLabel)
+	if err != nil {
+		return false, []string{fmt.Sprintf("invalid app config label %q, %v", a.appConfigLabel, err)}
+	}
+	if !selector.Matches(labels.Set(cm.Labels)) {
+		return false, nil
+	}
+	nsCtx, cancel := context.WithTimeout(ctx, namespaceLookupTimeout)
+	defer cancel()
+	selected, err := a.namespaceSelected(nsCtx, cm.Namespace)
+	if err != nil {
+		// Validated as before if it's unknown whether the namespace is aggregated.
+		a.logger.Warnw("Failed to check the namespace selector", zap.String("namespace", cm.Namespace), zap.Error(err))
+	} else if !selected {
+		return false, nil
+	}
+	return true, a.ValidateConfigMapEntries(cm)
+}
+
+// ValidateConfigMapEntries validates all the entries of a ConfigMap regardless of its labels, it returns
+// the errors of the invalid entries.
+func (a *aggregator) ValidateConfigMapEntries(cm *corev1.ConfigMap) []string {
+	src := newConfigMapSource(cm)
+	a.convertSource(src)
+	return src.errors
+}
+
+// ValidateConfig validates an application config.
+func (a *aggregator) ValidateConfig(config string) error {
+	_, err := a.convert(config)
+	return err
+}
@@ -0,0 +1,61 @@
+package aggregator
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_ValidateConfigMap(t *testing.T) {
+	path, err := os.Getwd()
+	assert.NoError(t, err)
+	a := NewAggregator(k8sfake.NewSimpleClientset(), "test-ns", "test-cm", WithSchemaFileDir(path+"/../../manifests/install/base"))
+
+	matched, errs := a.ValidateConfigMap(context.Background(), fakeAppConfigMap(t, "ns1", "n1"))
+	assert.True(t, matched)
+	assert.Empty(t, errs)
+
+	invalid := fakeAppConfigMap(t, "ns1", "n2")
+	invalid.Data["world"] = "service: [oops"
+	matched, errs = a.ValidateConfigMap(context.Background(), invalid)
+	assert.True(t, matched)
+	assert.Len(t, errs, 1)
+	assert.Contains(t, errs[0], "world: ")
+
+	invalid.Labels = nil
+	matched, _ = a.ValidateConfigMap(context.Background(), invalid)
+	assert.False(t, matched)
+}
+
+func Test_ValidateConfigMap_namespaceSelector(t *testing.T) {
+	path, err := os.Getwd()
+	assert.NoError(t, err)
+	client := k8sfake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns1", Labels: map[string]string{"numalogic": "enabled"}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns2"}},
+	)
+	a := NewAggregator(client, "test-ns", "test-cm", WithSchemaFileDir(path+"/../../manifests/install/base"), WithNamespaceSelector("numalogic=enabled"))
+
+	invalid := fakeAppConfigMap(t, "ns1", "n1")
+	invalid.Data["world"] = "service: [oops"
+	matched, errs := a.ValidateConfigMap(context.Background(), invalid)
+	assert.True(t, matched)
+	assert.Len(t, errs, 1)
+
+	// The namespace is not aggregated.
+	invalid.Namespace = "ns2"
+	matched, errs = a.ValidateConfigMap(context.Background(), invalid)
+	assert.False(t, matched)
+	assert.Empty(t, errs)
+
+	// Validated if the namespace is unknown.
+	invalid.Namespace = "ns3"
+	matched, errs = a.ValidateConfigMap(context.Background(), invalid)
+	assert.True(t, matched)
+	assert.Len(t, errs, 1)
+}
@@ -0,0 +1,196 @@
+package aggregator
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/numaproj-labs/numalogic-config-aggregator/pkg/apis/numalogic/v1alpha1"
+)
+
+// Start watching the application config sources, every add/update/delete event sends a signal
+// to the trigger channel. A source failing to start doesn't stop the others, the failed ones are
+// only read in the periodical runs.
+func (a *aggregator) startInformer(ctx context.Context, trigger chan<- struct{}) error {
+	notify := func() {
+		select {
+		case trigger <- struct{}{}:
+		default: // There's already a pending signal.
+		}
+	}
+	var errs []error
+	for _, source := range a.sources {
+		if err := source.Start(ctx, notify); err != nil {
+			errs = append(errs, fmt.Errorf("failed to start watching %s, %w", source, err))
+		}
+	}
+	if a.namespaceSelector != "" {
+		if err := a.startNamespaceInformer(ctx, notify); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// An informer of the application config sources in a namespace.
+type namespacedInformer struct {
+	namespace string
+	start     func(stopCh <-chan struct{})
+	synced    cache.InformerSynced
+}
+
+// Start the informers and wait for their caches to sync within the sync timeout, e.g. they never sync if
+// the list is forbidden. It returns the namespaces of the informers not synced, all the informers are stopped
+// in this case, so the sources are either all watched or all listed in the periodical runs.
+func (a *aggregator) startAndSync(ctx context.Context, nsInformers []namespacedInformer) []string {
+	informerCtx, stop := context.WithCancel(ctx)
+	var synced []cache.InformerSynced
+	for _, i := range nsInformers {
+		i.start(informerCtx.Done())
+		synced = append(synced, i.synced)
+	}
+	syncCtx, cancel := context.WithTimeout(ctx, a.syncTimeout)
+	defer cancel()
+	if !cache.WaitForCacheSync(syncCtx.Done(), synced...) {
+		stop()
+		var failed []string
+		for _, i := range nsInformers {
+			if !i.synced() {
+				failed = append(failed, namespaceName(i.namespace))
+			}
+		}
+		return failed
+	}
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return nil
+}
+
+func namespaceName(ns string) string {
+	if ns == metav1.NamespaceAll {
+		return "all namespaces"
+	}
+	return ns
+}
+
+func (a *aggregator) startConfigMapInformer(ctx context.Context, notify func()) error {
+	var nsInformers []namespacedInformer
+	var listers []corelisters.ConfigMapLister
+	for _, ns := range a.watchedNamespaces() {
+		factory := informers.NewSharedInformerFactoryWithOptions(a.k8sclient, 0, informers.WithNamespace(ns), informers.WithTweakListOptions(func(o *metav1.ListOptions) {
+			o.LabelSelector = a.appConfigLabel
+		}))
+		cmInformer := factory.Core().V1().ConfigMaps()
+		_, err := cmInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) {
+				notify()
+			},
+			UpdateFunc: func(oldObj, newObj interface{}) {
+				oldCm, ok1 := oldObj.(*corev1.ConfigMap)
+				newCm, ok2 := newObj.(*corev1.ConfigMap)
+				if ok1 && ok2 && !configMapChanged(oldCm, newCm) {
+					return
+				}
+				notify()
+			},
+			DeleteFunc: func(obj interface{}) {
+				notify()
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to add configmap event handler, %w", err)
+		}
+		nsInformers = append(nsInformers, namespacedInformer{namespace: ns, start: factory.Start, synced: cmInformer.Informer().HasSynced})
+		listers = append(listers, cmInformer.Lister())
+	}
+	if failed := a.startAndSync(ctx, nsInformers); len(failed) > 0 {
+		return fmt.Errorf("failed to sync informer cache of the configmaps in %s within %s, the list may be forbidden", strings.Join(failed, ", "), a.syncTimeout)
+	}
+	a.cmListers = listers
+	return nil
+}
+
+func (a *aggregator) startAppConfigResourceInformer(ctx context.Context, notify func()) error {
+	var nsInformers []namespacedInformer
+	var listers []cache.GenericLister
+	for _, ns := range a.watchedNamespaces() {
+		factory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(a.dynamicClient, 0, ns, nil)
+		acInformer := factory.ForResource(v1alpha1.NumalogicAppConfigGroupVersionResource)
+		_, err := acInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) {
+				notify()
+			},
+			UpdateFunc: func(oldObj, newObj interface{}) {
+				oldAc, ok1 := oldObj.(metav1.Object)
+				newAc, ok2 := newObj.(metav1.Object)
+				// Status updates do not change the generation.
+				if ok1 && ok2 && oldAc.GetGeneration() == newAc.GetGeneration() && reflect.DeepEqual(oldAc.GetAnnotations(), newAc.GetAnnotations()) {
+					return
+				}
+				notify()
+			},
+			DeleteFunc: func(obj interface{}) {
+				notify()
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to add %s event handler, %w", KindNumalogicAppConfig, err)
+		}
+		nsInformers = append(nsInformers, namespacedInformer{namespace: ns, start: factory.Start, synced: acInformer.Informer().HasSynced})
+		listers = append(listers, acInformer.Lister())
+	}
+	if failed := a.startAndSync(ctx, nsInformers); len(failed) > 0 {
+		return fmt.Errorf("failed to sync informer cache of the %s resources in %s within %s, the list may be forbidden or the CRD may be missing", KindNumalogicAppConfig, strings.Join(failed, ", "), a.syncTimeout)
+	}
+	a.acListers = listers
+	return nil
+}
+
+// Only the changes of data or labels matter to the aggregation.
+func configMapChanged(oldCm, newCm *corev1.ConfigMap) bool {
+	if oldCm.ResourceVersion == newCm.ResourceVersion {
+		return false
+	}
+	return !reflect.DeepEqual(oldCm.Data, newCm.Data) || !reflect.DeepEqual(oldCm.Labels, newCm.Labels)
+}
+
+// List the application ConfigMaps, from the informer cache if it's available.
+func (a *aggregator) listAppConfigMaps(ctx context.Context) ([]corev1.ConfigMap, error) {
+	result := []corev1.ConfigMap{}
+	if len(a.cmListers) > 0 {
+		selector, err := labels.Parse(a.appConfigLabel)
+		if err != nil {
+			return nil, fmt.Errorf("invalid app config label %q, %w", a.appConfigLabel, err)
+		}
+		for _, lister := range a.cmListers {
+			cms, err := lister.List(selector)
+			if err != nil {
+				return nil, err
+			}
+			for _, cm := range cms {
+				result = append(result, *cm.DeepCopy())
+			}
+		}
+		return result, nil
+	}
+	for _, ns := range a.watchedNamespaces() {
+		cmList, err := a.k8sclient.CoreV1().ConfigMaps(ns).List(ctx, metav1.ListOptions{LabelSelector: a.appConfigLabel})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the application configmaps in %s, %w", namespaceName(ns), err)
+		}
+		result = append(result, cmList.Items...)
+	}
+	return result, nil
+}
@@ -0,0 +1,57 @@
+package aggregator
+
+import (
+	"context"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func Test_Run_informerSyncTimeout(t *testing.T) {
+	k8sCli := k8sfake.NewSimpleClientset(fakeAppConfigMap(t, "ns1", "n1"))
+	var forbidden atomic.Bool
+	forbidden.Store(true)
+	k8sCli.PrependReactor("list", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if forbidden.Load() && action.GetNamespace() != "test-ns" {
+			return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: "configmaps"}, "", nil)
+		}
+		return false, nil, nil
+	})
+	path, err := os.Getwd()
+	assert.NoError(t, err)
+	a := NewAggregator(k8sCli, "test-ns", "test-cm", WithSchemaFileDir(path+"/../../manifests/install/base"), WithDebounce(10*time.Millisecond), WithInterval(200*time.Millisecond))
+	a.syncTimeout = 100 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		a.Run(ctx)
+		close(done)
+	}()
+	// The loop runs, and fails, instead of waiting for the informer forever.
+	assert.Eventually(t, func() bool {
+		a.state.lock.RLock()
+		defer a.state.lock.RUnlock()
+		return !a.state.runStartedAt.IsZero()
+	}, 2*time.Second, 20*time.Millisecond)
+	assert.Error(t, a.Ready())
+
+	// It falls back to the periodical runs listing the ConfigMaps.
+	forbidden.Store(false)
+	assert.Eventually(t, func() bool {
+		_, err := k8sCli.CoreV1().ConfigMaps("test-ns").Get(ctx, "test-cm", metav1.GetOptions{})
+		return err == nil
+	}, 2*time.Second, 20*time.Millisecond)
+	cancel()
+	<-done
+	assert.Empty(t, a.cmListers)
+}
@@ -0,0 +1,4 @@
+// Package v1alpha1 is the v1alpha1 version of the numalogic.numaproj.io API.
+// +kubebuilder:object:generate=true
+// +groupName=numalogic.numaproj.io
+package v1alpha1
@@ -0,0 +1,88 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	// ConditionValid is the condition type showing whether the application config is valid.
+	ConditionValid = "Valid"
+	// ConditionAggregated is the condition type showing whether the application config is in the aggregation.
+	ConditionAggregated = "Aggregated"
+)
+
+// NumalogicAppConfig is the numalogic configuration of an application.
+// +genclient
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:shortName=nac
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Service",type=string,JSONPath=`.spec.service`
+// +kubebuilder:printcolumn:name="Valid",type=string,JSONPath=`.status.conditions[?(@.type=="Valid")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+type NumalogicAppConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec NumalogicAppConfigSpec `json:"spec"`
+	// +optional
+	Status NumalogicAppConfigStatus `json:"status,omitempty"`
+}
+
+// NumalogicAppConfigSpec mirrors the ServiceConf in the schema of the application config.
+type NumalogicAppConfigSpec struct {
+	// +optional
+	Service string `json:"service,omitempty"`
+	// +optional
+	MetricConfigs []MetricConfig `json:"metricConfigs,omitempty"`
+	// +optional
+	UnifiedConfigs []UnifiedConfig `json:"unifiedConfigs,omitempty"`
+}
+
+type MetricConfig struct {
+	Metric string `json:"metric"`
+	// +optional
+	CompositeKeys []string `json:"compositeKeys,omitempty"`
+	// +optional
+	StaticThreshold *int64 `json:"staticThreshold,omitempty"`
+	// A decimal number in string, e.g. "0.5".
+	// +kubebuilder:validation:Pattern=`^[0-9]+(\.[0-9]+)?$`
+	// +optional
+	StaticThresholdWt string `json:"staticThresholdWt,omitempty"`
+	// +optional
+	ScrapeInterval *int64 `json:"scrapeInterval,omitempty"`
+	// +optional
+	RetrainFreqHr *int64 `json:"retrainFreqHr,omitempty"`
+	// +optional
+	ResumeTraining *bool `json:"resumeTraining,omitempty"`
+	// The NumalogicConf in the schema, it's passed through as is.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:validation:Type=object
+	// +optional
+	NumalogicConf *runtime.RawExtension `json:"numalogicConf,omitempty"`
+}
+
+type UnifiedConfig struct {
+	UnifiedMetricName string   `json:"unifiedMetricName"`
+	UnifiedMetrics    []string `json:"unifiedMetrics"`
+	// +optional
+	UnifiedStrategy string `json:"unifiedStrategy,omitempty"`
+}
+
+type NumalogicAppConfigStatus struct {
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// The generation of the spec in the aggregation.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+}
+
+// NumalogicAppConfigList is a list of NumalogicAppConfig.
+// +kubebuilder:object:root=true
+type NumalogicAppConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []NumalogicAppConfig `json:"items"`
+}
@@ -0,0 +1,185 @@
+//go:build !ignore_autogenerated
+
+// Code generated by controller-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetricConfig) DeepCopyInto(out *MetricConfig) {
+	*out = *in
+	if in.CompositeKeys != nil {
+		in, out := &in.CompositeKeys, &out.CompositeKeys
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.StaticThreshold != nil {
+		in, out := &in.StaticThreshold, &out.StaticThreshold
+		*out = new(int64)
+		**out = **in
+	}
+	if in.ScrapeInterval != nil {
+		in, out := &in.ScrapeInterval, &out.ScrapeInterval
+		*out = new(int64)
+		**out = **in
+	}
+	if in.RetrainFreqHr != nil {
+		in, out := &in.RetrainFreqHr, &out.RetrainFreqHr
+		*out = new(int64)
+		**out = **in
+	}
+	if in.ResumeTraining != nil {
+		in, out := &in.ResumeTraining, &out.ResumeTraining
+		*out = new(bool)
+		**out = **in
+	}
+	if in.NumalogicConf != nil {
+		in, out := &in.NumalogicConf, &out.NumalogicConf
+		*out = new(runtime.RawExtension)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetricConfig.
+func (in *MetricConfig) DeepCopy() *MetricConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(MetricConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NumalogicAppConfig) DeepCopyInto(out *NumalogicAppConfig) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NumalogicAppConfig.
+func (in *NumalogicAppConfig) DeepCopy() *NumalogicAppConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(NumalogicAppConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *NumalogicAppConfig) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NumalogicAppConfigList) DeepCopyInto(out *NumalogicAppConfigList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]NumalogicAppConfig, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NumalogicAppConfigList.
+func (in *NumalogicAppConfigList) DeepCopy() *NumalogicAppConfigList {
+	if in == nil {
+		return nil
+	}
+	out := new(NumalogicAppConfigList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *NumalogicAppConfigList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NumalogicAppConfigSpec) DeepCopyInto(out *NumalogicAppConfigSpec) {
+	*out = *in
+	if in.MetricConfigs != nil {
+		in, out := &in.MetricConfigs, &out.MetricConfigs
+		*out = make([]MetricConfig, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.UnifiedConfigs != nil {
+		in, out := &in.UnifiedConfigs, &out.UnifiedConfigs
+		*out = make([]UnifiedConfig, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NumalogicAppConfigSpec.
+func (in *NumalogicAppConfigSpec) DeepCopy() *NumalogicAppConfigSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(NumalogicAppConfigSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NumalogicAppConfigStatus) DeepCopyInto(out *NumalogicAppConfigStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NumalogicAppConfigStatus.
+func (in *NumalogicAppConfigStatus) DeepCopy() *NumalogicAppConfigStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(NumalogicAppConfigStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UnifiedConfig) DeepCopyInto(out *UnifiedConfig) {
+	*out = *in
+	if in.UnifiedMetrics != nil {
+		in, out := &in.UnifiedMetrics, &out.UnifiedMetrics
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UnifiedConfig.
+func (in *UnifiedConfig) DeepCopy() *UnifiedConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(UnifiedConfig)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,35 @@
+// Package cmd implements the offline subcommands, which work on local files without a cluster.
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/numaproj-labs/numalogic-config-aggregator/pkg/aggregator"
+)
+
+// The aggregator functions used offline.
+type offlineAggregator interface {
+	// Ready returns the error of loading the schema.
+	Ready() error
+	ValidateConfig(string) error
+	ValidateConfigMapEntries(*corev1.ConfigMap) []string
+	AggregateConfigMaps([]corev1.ConfigMap) (aggregator.GlobalConfig, []string, error)
+}
+
+// Create an aggregator without a cluster, only to validate and aggregate local files.
+func newOfflineAggregator(schema string, opts ...aggregator.Option) (offlineAggregator, error) {
+	schema, err := filepath.Abs(schema)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema path %q, %w", schema, err)
+	}
+	opts = append([]aggregator.Option{aggregator.WithName(schema), aggregator.WithSchemaFile(schema), aggregator.WithLogger(zap.NewNop().Sugar())}, opts...)
+	a := aggregator.NewAggregator(nil, "", "", opts...)
+	if err := a.Ready(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
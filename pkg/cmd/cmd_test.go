@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const appConfig = `service: test
+metric_configs:
+- metric: m1
+  composite_keys:
+  - ck11
+  static_threshold: 1
+`
+
+const appConfigMaps = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: n1
+  namespace: ns1
+  labels:
+    numaprom.numaproj.io/component: argo-rollouts
+data:
+  hello: |
+    service: test
+    metric_configs:
+    - metric: m1
+      composite_keys:
+      - ck11
+      static_threshold: 1
+---
+apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: n2
+    namespace: ns2
+    labels:
+      numaprom.numaproj.io/component: argo-rollouts
+  data:
+    hello: |
+      service: test2
+      metric_configs:
+      - metric: m2
+        composite_keys:
+        - ck21
+        static_threshold: 2
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: other
+    namespace: ns2
+  data:
+    hello: "not: [valid"
+`
+
+func schemaPath(t *testing.T) string {
+	t.Helper()
+	path, err := os.Getwd()
+	assert.NoError(t, err)
+	return path + "/../../manifests/install/base/schema.json"
+}
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	f := filepath.Join(dir, name)
+	assert.NoError(t, os.WriteFile(f, []byte(content), 0o600))
+	return f
+}
+
+func Test_readDocuments(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "a.yaml", appConfig)
+	writeFile(t, dir, "b.yaml", appConfigMaps)
+	writeFile(t, dir, "README.md", "# hello")
+	docs, err := readDocuments([]string{dir})
+	assert.NoError(t, err)
+	assert.Len(t, docs, 4)
+	assert.Nil(t, docs[0].configMap)
+	assert.Equal(t, appConfig, docs[0].raw)
+	assert.Equal(t, "n1", docs[1].configMap.Name)
+	assert.Equal(t, 0, docs[1].index)
+	assert.Equal(t, "n2", docs[2].configMap.Name)
+	assert.Equal(t, "other", docs[3].configMap.Name)
+	assert.Equal(t, 1, docs[3].index)
+
+	_, err = readDocuments([]string{filepath.Join(dir, "not-existing.yaml")})
+	assert.Error(t, err)
+}
@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+)
+
+// A YAML document read from a local file, either a ConfigMap manifest or an application config.
+type document struct {
+	file string
+	// The index of the document in a multi-document file, starting from 0
+	index int
+	// The ConfigMap if the document is a ConfigMap manifest, or an item of a List manifest
+	configMap *corev1.ConfigMap
+	// The raw document if it's not a ConfigMap manifest
+	raw string
+}
+
+// Read all the YAML documents in the paths, directories are walked for *.yaml, *.yml and *.json files.
+func readDocuments(paths []string) ([]document, error) {
+	var files []string
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s, %w", p, err)
+		}
+		if !info.IsDir() {
+			files = append(files, p)
+			continue
+		}
+		err = filepath.WalkDir(p, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			switch strings.ToLower(filepath.Ext(path)) {
+			case ".yaml", ".yml", ".json":
+				if !d.IsDir() {
+					files = append(files, path)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to walk %s, %w", p, err)
+		}
+	}
+	sort.Strings(files)
+	var docs []document
+	for _, f := range files {
+		fileDocs, err := readFile(f)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, fileDocs...)
+	}
+	return docs, nil
+}
+
+func readFile(f string) ([]document, error) {
+	content, err := os.ReadFile(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s, %w", f, err)
+	}
+	var docs []document
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(content)))
+	for index := 0; ; index++ {
+		raw, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return docs, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s, %w", f, err)
+		}
+		if len(bytes.TrimSpace(raw)) == 0 {
+			index--
+			continue
+		}
+		cms, err := configMaps(raw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read document %d of %s, %w", index, f, err)
+		}
+		if cms == nil {
+			docs = append(docs, document{file: f, index: index, raw: string(raw)})
+			continue
+		}
+		for i := range cms {
+			docs = append(docs, document{file: f, index: index, configMap: &cms[i]})
+		}
+	}
+}
+
+// Decode the ConfigMaps in a ConfigMap or List manifest, it returns nil if the document is neither of them.
+func configMaps(raw []byte) ([]corev1.ConfigMap, error) {
+	var typeMeta metav1.TypeMeta
+	// Not a Kubernetes manifest if it fails, e.g. a list
+	if err := yaml.Unmarshal(raw, &typeMeta); err != nil {
+		return nil, nil
+	}
+	switch {
+	case typeMeta.APIVersion == "v1" && typeMeta.Kind == "ConfigMap":
+		cm := corev1.ConfigMap{}
+		if err := yaml.Unmarshal(raw, &cm); err != nil {
+			return nil, fmt.Errorf("failed to decode the ConfigMap, %w", err)
+		}
+		return []corev1.ConfigMap{cm}, nil
+	case typeMeta.APIVersion == "v1" && (typeMeta.Kind == "List" || typeMeta.Kind == "ConfigMapList"):
+		list := corev1.ConfigMapList{}
+		if err := yaml.Unmarshal(raw, &list); err != nil {
+			return nil, fmt.Errorf("failed to decode the List, %w", err)
+		}
+		result := []corev1.ConfigMap{}
+		for _, item := range list.Items {
+			if item.Kind == "" || item.Kind == "ConfigMap" {
+				result = append(result, item)
+			}
+		}
+		return result, nil
+	default:
+		return nil, nil
+	}
+}
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/numaproj-labs/numalogic-config-aggregator/pkg/aggregator"
+)
+
+// Render aggregates the application configs from the local ConfigMap manifests, and prints the aggregated
+// config. The errors of the invalid configs are printed to stderr, they are skipped as in the cluster.
+// It returns the exit code, 2 if it fails to aggregate.
+func Render(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("render", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	var schema, appConfigLabel, includeNamespaces, excludeNamespaces string
+	var mergeNamespace bool
+	flags.StringVar(&schema, "schema", "", "Path of the schema.json file")
+	flags.StringVar(&appConfigLabel, "app-config-label", "", "Label of the application ConfigMaps, defaults to the one of the aggregator")
+	flags.StringVar(&includeNamespaces, "include-namespaces", "", "Comma separated namespaces to read the application configs from, all namespaces if it's empty")
+	flags.StringVar(&excludeNamespaces, "exclude-namespaces", "", "Regex of the namespaces not to read the application configs from")
+	flags.BoolVar(&mergeNamespace, "merge-namespace", false, "Merge the configs from multiple ConfigMaps in one namespace into one config per service")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: numalogic-config-aggregator render --schema <schema.json> <file or dir>...")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if schema == "" || flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+	opts := []aggregator.Option{aggregator.WithNamespaceMerge(mergeNamespace)}
+	if appConfigLabel != "" {
+		opts = append(opts, aggregator.WithAppConfigLabel(appConfigLabel))
+	}
+	if includeNamespaces != "" {
+		opts = append(opts, aggregator.WithIncludeNamespaces(strings.Split(includeNamespaces, ",")))
+	}
+	if excludeNamespaces != "" {
+		r, err := regexp.Compile(excludeNamespaces)
+		if err != nil {
+			fmt.Fprintf(stderr, "invalid exclude namespaces regex, %v\n", err)
+			return 2
+		}
+		opts = append(opts, aggregator.WithExcludeNamespaces(r))
+	}
+	a, err := newOfflineAggregator(schema, opts...)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	docs, err := readDocuments(flags.Args())
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	var cms []corev1.ConfigMap
+	for _, doc := range docs {
+		if doc.configMap != nil {
+			cms = append(cms, *doc.configMap)
+		}
+	}
+	config, errs, err := a.AggregateConfigMaps(cms)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	for _, e := range errs {
+		fmt.Fprintln(stderr, e)
+	}
+	configBytes, err := yaml.Marshal(&config)
+	if err != nil {
+		fmt.Fprintf(stderr, "failed to marshal configuration, %v\n", err)
+		return 2
+	}
+	_, _ = stdout.Write(configBytes)
+	return 0
+}
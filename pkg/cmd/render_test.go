@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/yaml"
+
+	"github.com/numaproj-labs/numalogic-config-aggregator/pkg/aggregator"
+)
+
+func Test_Render(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "manifests.yaml", appConfigMaps)
+	writeFile(t, dir, "raw.yaml", appConfig)
+
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, 0, Render([]string{"--schema", schemaPath(t), dir}, &stdout, &stderr))
+	var c aggregator.GlobalConfig
+	assert.NoError(t, yaml.Unmarshal(stdout.Bytes(), &c))
+	// The unlabeled ConfigMap and the raw config are skipped.
+	assert.Len(t, c.Configs, 2)
+	assert.Equal(t, "ns1", c.Configs[0][aggregator.Namespace])
+	assert.Equal(t, "ns2", c.Configs[1][aggregator.Namespace])
+	assert.Empty(t, stderr.String())
+
+	stdout.Reset()
+	assert.Equal(t, 0, Render([]string{"--schema", schemaPath(t), "--app-config-label", "!numaprom.numaproj.io/component", dir}, &stdout, &stderr))
+	assert.NoError(t, yaml.Unmarshal(stdout.Bytes(), &c))
+	assert.Empty(t, c.Configs)
+	assert.Contains(t, stderr.String(), "ConfigMap ns2/other: hello: ")
+
+	stdout.Reset()
+	stderr.Reset()
+	assert.Equal(t, 0, Render([]string{"--schema", schemaPath(t), "--include-namespaces", "ns1,ns3", dir}, &stdout, &stderr))
+	c = aggregator.GlobalConfig{}
+	assert.NoError(t, yaml.Unmarshal(stdout.Bytes(), &c))
+	assert.Len(t, c.Configs, 1)
+	assert.Equal(t, "ns1", c.Configs[0][aggregator.Namespace])
+
+	stdout.Reset()
+	assert.Equal(t, 0, Render([]string{"--schema", schemaPath(t), "--exclude-namespaces", "^ns1$", dir}, &stdout, &stderr))
+	c = aggregator.GlobalConfig{}
+	assert.NoError(t, yaml.Unmarshal(stdout.Bytes(), &c))
+	assert.Len(t, c.Configs, 1)
+	assert.Equal(t, "ns2", c.Configs[0][aggregator.Namespace])
+	assert.Empty(t, stderr.String())
+
+	assert.Equal(t, 2, Render([]string{"--schema", schemaPath(t), "--exclude-namespaces", "[oops", dir}, &stdout, &stderr))
+	assert.Equal(t, 2, Render([]string{dir}, &stdout, &stderr))
+}
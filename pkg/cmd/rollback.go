@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/numaproj-labs/numalogic-config-aggregator/pkg/aggregator"
+)
+
+// Rollback lists the revisions of an aggregated ConfigMap, pins it to a revision, or unpins it.
+// A pinned ConfigMap keeps the config of the revision until it's unpinned.
+// It returns the exit code, 2 if it fails.
+func Rollback(ctx context.Context, client kubernetes.Interface, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("rollback", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	var namespace, configMapName, configMapKey string
+	var to, maxConfigMapSize int
+	var unpin, list bool
+	flags.StringVar(&namespace, "namespace", os.Getenv("NAMESPACE"), "Namespace of the aggregated ConfigMap, defaults to the NAMESPACE env variable")
+	flags.StringVar(&configMapName, "configmap-name", "", "Aggregated ConfigMap name")
+	flags.StringVar(&configMapKey, "configmap-key", aggregator.DefaultConfigMapKey, "Key of the aggregated ConfigMap")
+	flags.IntVar(&to, "to", 0, "Revision to roll back to and pin the aggregated ConfigMap to")
+	flags.BoolVar(&unpin, "unpin", false, "Unpin the aggregated ConfigMap, it's updated with the latest aggregated config in the next run")
+	flags.BoolVar(&list, "list", false, "List the revisions")
+	flags.IntVar(&maxConfigMapSize, "max-configmap-size", aggregator.DefaultMaxConfigMapSize, "Max size in bytes of the aggregated config in one ConfigMap, same as the one of the aggregator")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: numalogic-config-aggregator rollback --configmap-name <name> (--list | --to <revision> | --unpin)")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	actions := 0
+	for _, set := range []bool{list, unpin, to != 0} {
+		if set {
+			actions++
+		}
+	}
+	if configMapName == "" || actions != 1 || to < 0 {
+		flags.Usage()
+		return 2
+	}
+	if namespace == "" {
+		namespace = "default"
+	}
+	switch {
+	case list:
+		revisions, err := aggregator.ListRevisions(ctx, client, namespace, configMapName)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+		w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "REVISION\tTIME\tHASH\tCHANGED SOURCES")
+		for _, r := range revisions {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", r.Number, r.Time.Format(time.RFC3339), r.Hash, strings.Join(r.ChangedSources, ", "))
+		}
+		_ = w.Flush()
+	case unpin:
+		if err := aggregator.UnpinRevision(ctx, client, namespace, configMapName); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+		fmt.Fprintf(stdout, "configmap %s/%s unpinned\n", namespace, configMapName)
+	default:
+		if err := aggregator.PinRevision(ctx, client, namespace, configMapName, configMapKey, to, maxConfigMapSize); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+		fmt.Fprintf(stdout, "configmap %s/%s rolled back and pinned to revision %d\n", namespace, configMapName, to)
+	}
+	return 0
+}
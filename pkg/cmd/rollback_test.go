@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/numaproj-labs/numalogic-config-aggregator/pkg/aggregator"
+)
+
+func Test_Rollback(t *testing.T) {
+	ctx := context.Background()
+	k8sCli := k8sfake.NewSimpleClientset(
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "cm"}, Data: map[string]string{"config.yaml": "configs: []\n"}},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "ns",
+				Name:      "cm-rev-1",
+				Labels:    map[string]string{aggregator.RevisionOfLabel: "cm"},
+				Annotations: map[string]string{
+					aggregator.RevisionAnnotation:       "1",
+					aggregator.RevisionTimeAnnotation:   "2023-05-01T00:00:00Z",
+					aggregator.RevisionHashAnnotation:   "abc",
+					aggregator.ChangedSourcesAnnotation: `["ConfigMap ns1/n1"]`,
+				},
+			},
+			Data: map[string]string{"config.yaml": "configs:\n- name: n1\n"},
+		},
+	)
+	getConfigMap := func() *corev1.ConfigMap {
+		cm, err := k8sCli.CoreV1().ConfigMaps("ns").Get(ctx, "cm", metav1.GetOptions{})
+		assert.NoError(t, err)
+		return cm
+	}
+
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, 0, Rollback(ctx, k8sCli, []string{"--namespace", "ns", "--configmap-name", "cm", "--list"}, &stdout, &stderr))
+	assert.Contains(t, stdout.String(), "2023-05-01T00:00:00Z  abc   ConfigMap ns1/n1")
+
+	assert.Equal(t, 0, Rollback(ctx, k8sCli, []string{"--namespace", "ns", "--configmap-name", "cm", "--to", "1"}, &stdout, &stderr))
+	cm := getConfigMap()
+	assert.Equal(t, "1", cm.Annotations[aggregator.PinnedRevisionAnnotation])
+	assert.Equal(t, "configs:\n- name: n1\n", cm.Data["config.yaml"])
+
+	assert.Equal(t, 0, Rollback(ctx, k8sCli, []string{"--namespace", "ns", "--configmap-name", "cm", "--unpin"}, &stdout, &stderr))
+	assert.NotContains(t, getConfigMap().Annotations, aggregator.PinnedRevisionAnnotation)
+
+	assert.Equal(t, 2, Rollback(ctx, k8sCli, []string{"--namespace", "ns", "--configmap-name", "cm", "--to", "2"}, &stdout, &stderr))
+	assert.Equal(t, 2, Rollback(ctx, k8sCli, []string{"--namespace", "ns", "--configmap-name", "cm", "--to", "1", "--unpin"}, &stdout, &stderr))
+	assert.Equal(t, 2, Rollback(ctx, k8sCli, []string{"--namespace", "ns", "--list"}, &stdout, &stderr))
+}
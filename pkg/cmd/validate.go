@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/numaproj-labs/numalogic-config-aggregator/pkg/aggregator"
+)
+
+// An error of validating an application config document.
+type validationError struct {
+	File string `json:"file"`
+	// The index of the document in the file
+	Document  int    `json:"document"`
+	Kind      string `json:"kind,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Error     string `json:"error"`
+}
+
+type validationResult struct {
+	Valid     bool              `json:"valid"`
+	Documents int               `json:"documents"`
+	Errors    []validationError `json:"errors"`
+}
+
+// Validate validates the application config files or ConfigMap manifests with the schema, in the same way
+// as they are aggregated. It returns the exit code, 1 if any of them is invalid, 2 if it fails to validate.
+func Validate(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("validate", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	var schema, output string
+	flags.StringVar(&schema, "schema", "", "Path of the schema.json file")
+	flags.StringVar(&output, "output", "text", "Output format, \"text\" or \"json\"")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: numalogic-config-aggregator validate --schema <schema.json> <file or dir>...")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if schema == "" || flags.NArg() == 0 || (output != "text" && output != "json") {
+		flags.Usage()
+		return 2
+	}
+	a, err := newOfflineAggregator(schema)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	docs, err := readDocuments(flags.Args())
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	result := validationResult{Documents: len(docs), Errors: []validationError{}}
+	for _, doc := range docs {
+		result.Errors = append(result.Errors, validateDocument(a, doc)...)
+	}
+	result.Valid = len(result.Errors) == 0
+	if output == "json" {
+		b, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+		fmt.Fprintln(stdout, string(b))
+	} else {
+		for _, e := range result.Errors {
+			if e.Kind != "" {
+				fmt.Fprintf(stdout, "%s#%d %s %s/%s: %s\n", e.File, e.Document, e.Kind, e.Namespace, e.Name, e.Error)
+			} else {
+				fmt.Fprintf(stdout, "%s#%d: %s\n", e.File, e.Document, e.Error)
+			}
+		}
+		fmt.Fprintf(stdout, "%d document(s) validated, %d error(s)\n", result.Documents, len(result.Errors))
+	}
+	if !result.Valid {
+		return 1
+	}
+	return 0
+}
+
+func validateDocument(a offlineAggregator, doc document) []validationError {
+	if doc.configMap == nil {
+		if err := a.ValidateConfig(doc.raw); err != nil {
+			return []validationError{{File: doc.file, Document: doc.index, Error: err.Error()}}
+		}
+		return nil
+	}
+	var result []validationError
+	for _, e := range a.ValidateConfigMapEntries(doc.configMap) {
+		result = append(result, validationError{
+			File:      doc.file,
+			Document:  doc.index,
+			Kind:      aggregator.KindConfigMap,
+			Namespace: doc.configMap.Namespace,
+			Name:      doc.configMap.Name,
+			Error:     e,
+		})
+	}
+	return result
+}
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Validate(t *testing.T) {
+	dir := t.TempDir()
+	valid := writeFile(t, dir, "valid.yaml", appConfig)
+	invalid := writeFile(t, dir, "invalid.yaml", "service: [oops")
+	manifests := writeFile(t, dir, "manifests.yaml", appConfigMaps)
+
+	t.Run("valid", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		assert.Equal(t, 0, Validate([]string{"--schema", schemaPath(t), valid}, &stdout, &stderr))
+		assert.Equal(t, "1 document(s) validated, 0 error(s)\n", stdout.String())
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		assert.Equal(t, 1, Validate([]string{"--schema", schemaPath(t), "--output", "json", valid, invalid, manifests}, &stdout, &stderr))
+		var result validationResult
+		assert.NoError(t, json.Unmarshal(stdout.Bytes(), &result))
+		assert.False(t, result.Valid)
+		assert.Equal(t, 5, result.Documents)
+		assert.Len(t, result.Errors, 2)
+		assert.Equal(t, invalid, result.Errors[0].File)
+		assert.Empty(t, result.Errors[0].Kind)
+		assert.Equal(t, "ConfigMap", result.Errors[1].Kind)
+		assert.Equal(t, "ns2", result.Errors[1].Namespace)
+		assert.Equal(t, "other", result.Errors[1].Name)
+		assert.Equal(t, 1, result.Errors[1].Document)
+		assert.Contains(t, result.Errors[1].Error, "hello: ")
+	})
+
+	t.Run("usage", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		assert.Equal(t, 2, Validate([]string{valid}, &stdout, &stderr))
+		assert.Equal(t, 2, Validate([]string{"--schema", dir + "/not-existing.json", valid}, &stdout, &stderr))
+	})
+}
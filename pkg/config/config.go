@@ -0,0 +1,290 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
+)
+
+// Config is the configuration of the aggregator process, it defines one or more aggregations.
+type Config struct {
+	Aggregations []Aggregation `json:"aggregations"`
+}
+
+// Aggregation defines an aggregation pipeline, the empty fields fall back to the defaults of the aggregator.
+type Aggregation struct {
+	// Name of the aggregation, used in the logs
+	Name string `json:"name"`
+	// Name of the aggregated ConfigMap
+	ConfigMapName string `json:"configMapName"`
+	// Namespace of the aggregated ConfigMap, defaults to the namespace of the aggregator
+	ConfigMapNamespace string `json:"configMapNamespace,omitempty"`
+	// Key of the config in the aggregated ConfigMap
+	ConfigMapKey string `json:"configMapKey,omitempty"`
+	// Label of the ConfigMaps in the application namespaces
+	AppConfigLabel string `json:"appConfigLabel,omitempty"`
+	// Dir of the schema.json file for validation
+	SchemaFileDir string `json:"schemaFileDir,omitempty"`
+	// Interval of each run
+	Interval *metav1.Duration `json:"interval,omitempty"`
+	// Whether to merge the configs from multiple ConfigMaps in one namespace
+	MergeNamespace bool `json:"mergeNamespace,omitempty"`
+	// Label selector of the namespaces to read the application configs from
+	NamespaceSelector string `json:"namespaceSelector,omitempty"`
+	// Namespaces to read the application configs from
+	IncludeNamespaces []string `json:"includeNamespaces,omitempty"`
+	// Regex of the namespaces not to read the application configs from
+	ExcludeNamespaces string `json:"excludeNamespaces,omitempty"`
+	// Whether to list and watch the application configs in each of the includeNamespaces instead of cluster wide
+	Namespaced bool `json:"namespaced,omitempty"`
+	// Max size in bytes of the aggregated config in one ConfigMap, it's split into the shards if it's larger
+	MaxConfigMapSize int `json:"maxConfigMapSize,omitempty"`
+	// Number of the revisions of the aggregated config to keep, defaults to 10, 0 disables the revisions
+	RevisionHistoryLimit *int `json:"revisionHistoryLimit,omitempty"`
+	// How long to keep the last valid version of an application config after it becomes invalid, defaults to 24h, 0 disables it
+	StaleConfigGracePeriod *metav1.Duration `json:"staleConfigGracePeriod,omitempty"`
+	// Max percentage of the published application configs removed in one run, defaults to 50, 0 means no limit
+	MaxRemovedPercent *int `json:"maxRemovedPercent,omitempty"`
+	// Max number of the published application configs removed in one run, defaults to 0, no limit
+	MaxRemovedConfigs *int `json:"maxRemovedConfigs,omitempty"`
+	// Additional destinations of the aggregated config, besides the aggregated ConfigMap
+	Sinks []Sink `json:"sinks,omitempty"`
+	// Local directories to read the application configs from, besides the cluster, each sub-directory is a namespace
+	SourceDirs []string `json:"sourceDirs,omitempty"`
+	// Git repositories to read the application configs from, besides the cluster
+	GitSources []GitSource `json:"gitSources,omitempty"`
+}
+
+// GitSource defines a git repository to read the application configs from.
+type GitSource struct {
+	// Path of the local checkout or bare repository
+	Repo string `json:"repo"`
+	// Ref to read, e.g. a branch, a tag or a commit, defaults to HEAD
+	Ref string `json:"ref,omitempty"`
+	// Pattern of the application config files, the name of the directory of a file is its namespace, defaults to "*/*.yaml"
+	PathPattern string `json:"pathPattern,omitempty"`
+}
+
+// Validate checks the required fields of the git source.
+func (g GitSource) Validate() error {
+	if g.Repo == "" {
+		return fmt.Errorf("repo of git source is missing")
+	}
+	if strings.HasPrefix(g.Ref, "-") {
+		return fmt.Errorf("invalid ref %q of git source", g.Ref)
+	}
+	if _, err := path.Match(g.PathPattern, ""); err != nil {
+		return fmt.Errorf("invalid pathPattern %q of git source, %w", g.PathPattern, err)
+	}
+	return nil
+}
+
+// SinkType is the type of a sink.
+type SinkType string
+
+const (
+	SinkTypeConfigMap SinkType = "configmap"
+	SinkTypeSecret    SinkType = "secret"
+	SinkTypeFile      SinkType = "file"
+	SinkTypeHTTP      SinkType = "http"
+	SinkTypeRedis     SinkType = "redis"
+)
+
+// Sink defines an additional destination of the aggregated config.
+type Sink struct {
+	Type SinkType `json:"type"`
+	// Namespace of the ConfigMap or Secret, defaults to the namespace of the aggregated ConfigMap
+	Namespace string `json:"namespace,omitempty"`
+	// Name of the ConfigMap or Secret
+	Name string `json:"name,omitempty"`
+	// Key in the ConfigMap or Secret, defaults to the key of the aggregated ConfigMap
+	Key string `json:"key,omitempty"`
+	// Path of the local file
+	Path string `json:"path,omitempty"`
+	// URL of the HTTP webhook, or the Redis server, e.g. redis://host:6379/0
+	URL string `json:"url,omitempty"`
+	// Headers of the HTTP requests
+	Headers map[string]string `json:"headers,omitempty"`
+	// Timeout of the HTTP requests, defaults to 10s
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+	// Name of the env variable of the Redis password
+	PasswordEnv string `json:"passwordEnv,omitempty"`
+	// Prefix of the Redis keys, defaults to "numalogic-config-aggregator:<aggregation name>"
+	KeyPrefix string `json:"keyPrefix,omitempty"`
+	// Whether to write one Redis key for each service besides the whole aggregated config
+	PerServiceKeys bool `json:"perServiceKeys,omitempty"`
+}
+
+// Validate checks the required fields of the sink.
+func (s Sink) Validate() error {
+	switch s.Type {
+	case SinkTypeConfigMap, SinkTypeSecret:
+		if s.Name == "" {
+			return fmt.Errorf("name of %s sink is missing", s.Type)
+		}
+	case SinkTypeFile:
+		if s.Path == "" {
+			return fmt.Errorf("path of file sink is missing")
+		}
+	case SinkTypeHTTP:
+		u, err := url.Parse(s.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("invalid url %q of http sink", s.URL)
+		}
+	case SinkTypeRedis:
+		if _, err := redis.ParseURL(s.URL); err != nil {
+			return fmt.Errorf("invalid url %q of redis sink, %w", s.URL, err)
+		}
+	default:
+		return fmt.Errorf("unknown sink type %q", s.Type)
+	}
+	return nil
+}
+
+// Load reads the configuration from a YAML file.
+func Load(path string) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file, %w", err)
+	}
+	c := &Config{}
+	if err := yaml.UnmarshalStrict(b, c); err != nil {
+		return nil, fmt.Errorf("failed to parse config file, %w", err)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Validate checks the required fields and the conflicts between the aggregations.
+func (c *Config) Validate() error {
+	if len(c.Aggregations) == 0 {
+		return fmt.Errorf("no aggregation is defined")
+	}
+	names := map[string]bool{}
+	for _, a := range c.Aggregations {
+		if a.Name == "" {
+			return fmt.Errorf("aggregation name is missing")
+		}
+		if names[a.Name] {
+			return fmt.Errorf("duplicate aggregation name %q", a.Name)
+		}
+		names[a.Name] = true
+		if a.ConfigMapName == "" {
+			return fmt.Errorf("configMapName of aggregation %q is missing", a.Name)
+		}
+		if a.Interval != nil && a.Interval.Duration <= 0 {
+			return fmt.Errorf("interval of aggregation %q must be positive", a.Name)
+		}
+		if _, err := labels.Parse(a.NamespaceSelector); err != nil {
+			return fmt.Errorf("invalid namespaceSelector of aggregation %q, %w", a.Name, err)
+		}
+		if _, err := regexp.Compile(a.ExcludeNamespaces); err != nil {
+			return fmt.Errorf("invalid excludeNamespaces of aggregation %q, %w", a.Name, err)
+		}
+		if a.Namespaced && len(a.IncludeNamespaces) == 0 {
+			return fmt.Errorf("includeNamespaces of namespaced aggregation %q is missing", a.Name)
+		}
+		if a.Namespaced && a.NamespaceSelector != "" {
+			return fmt.Errorf("namespaceSelector is not supported by namespaced aggregation %q", a.Name)
+		}
+		if a.RevisionHistoryLimit != nil && *a.RevisionHistoryLimit < 0 {
+			return fmt.Errorf("revisionHistoryLimit of aggregation %q must not be negative", a.Name)
+		}
+		if a.StaleConfigGracePeriod != nil && a.StaleConfigGracePeriod.Duration < 0 {
+			return fmt.Errorf("staleConfigGracePeriod of aggregation %q must not be negative", a.Name)
+		}
+		if a.MaxRemovedPercent != nil && (*a.MaxRemovedPercent < 0 || *a.MaxRemovedPercent > 100) {
+			return fmt.Errorf("maxRemovedPercent of aggregation %q must be between 0 and 100", a.Name)
+		}
+		if a.MaxRemovedConfigs != nil && *a.MaxRemovedConfigs < 0 {
+			return fmt.Errorf("maxRemovedConfigs of aggregation %q must not be negative", a.Name)
+		}
+		for _, dir := range a.SourceDirs {
+			if dir == "" {
+				return fmt.Errorf("empty sourceDirs entry of aggregation %q", a.Name)
+			}
+		}
+		for i, g := range a.GitSources {
+			if err := g.Validate(); err != nil {
+				return fmt.Errorf("invalid git source %d of aggregation %q, %w", i, a.Name, err)
+			}
+		}
+		for i, sink := range a.Sinks {
+			if err := sink.Validate(); err != nil {
+				return fmt.Errorf("invalid sink %d of aggregation %q, %w", i, a.Name, err)
+			}
+		}
+	}
+	return c.ValidateConfigMaps("")
+}
+
+// ValidateConfigMaps checks no two aggregations write to the same ConfigMap, with the namespaces defaulted to
+// the namespace of the aggregator. The shards, the revisions and the annotations belong to the whole ConfigMap,
+// so it can't be shared even with different keys.
+func (c *Config) ValidateConfigMaps(namespace string) error {
+	configMaps := map[string]string{}
+	add := func(ns, name, aggregation string) error {
+		if ns == "" {
+			ns = namespace
+		}
+		key := ns + "/" + name
+		if existing, ok := configMaps[key]; ok {
+			if existing == aggregation {
+				return fmt.Errorf("aggregation %q writes to the ConfigMap %s more than once", aggregation, key)
+			}
+			return fmt.Errorf("aggregations %q and %q write to the same ConfigMap %s", existing, aggregation, key)
+		}
+		configMaps[key] = aggregation
+		return nil
+	}
+	for _, a := range c.Aggregations {
+		if err := add(a.ConfigMapNamespace, a.ConfigMapName, a.Name); err != nil {
+			return err
+		}
+		for _, s := range a.Sinks {
+			if s.Type != SinkTypeConfigMap {
+				continue
+			}
+			ns := s.Namespace
+			if ns == "" {
+				ns = a.ConfigMapNamespace
+			}
+			if err := add(ns, s.Name, a.Name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateLocal checks the aggregations can run without a cluster, i.e. they read the application configs from
+// the source dirs or the git sources, and don't read the namespaces or write to the ConfigMaps or the Secrets.
+func (c *Config) ValidateLocal() error {
+	for _, a := range c.Aggregations {
+		if len(a.SourceDirs) == 0 && len(a.GitSources) == 0 {
+			return fmt.Errorf("sourceDirs or gitSources of aggregation %q are required in the local mode", a.Name)
+		}
+		if a.NamespaceSelector != "" {
+			return fmt.Errorf("namespaceSelector of aggregation %q is not supported in the local mode", a.Name)
+		}
+		if a.Namespaced {
+			return fmt.Errorf("namespaced aggregation %q is not supported in the local mode", a.Name)
+		}
+		for _, s := range a.Sinks {
+			if s.Type == SinkTypeConfigMap || s.Type == SinkTypeSecret {
+				return fmt.Errorf("%s sink of aggregation %q is not supported in the local mode", s.Type, a.Name)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(p, []byte(content), 0644))
+	return p
+}
+
+func Test_Load(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		c, err := Load(writeConfigFile(t, `aggregations:
+- name: argo-rollouts
+  configMapName: numaproj-argorollouts-configs
+  appConfigLabel: numaprom.numaproj.io/component=argo-rollouts
+  interval: 60s
+- name: others
+  configMapName: numaproj-others-configs
+  configMapNamespace: numalogic-others
+  schemaFileDir: /etc/config/others
+  mergeNamespace: true
+  sinks:
+  - type: secret
+    name: numaproj-others-configs
+  - type: file
+    path: /var/run/config/config.yaml
+  - type: http
+    url: https://example.com/configs
+    timeout: 5s
+`))
+		assert.NoError(t, err)
+		assert.Equal(t, 2, len(c.Aggregations))
+		assert.Equal(t, "argo-rollouts", c.Aggregations[0].Name)
+		assert.Equal(t, time.Minute, c.Aggregations[0].Interval.Duration)
+		assert.Equal(t, "numalogic-others", c.Aggregations[1].ConfigMapNamespace)
+		assert.True(t, c.Aggregations[1].MergeNamespace)
+		assert.Nil(t, c.Aggregations[1].Interval)
+		assert.Equal(t, 3, len(c.Aggregations[1].Sinks))
+		assert.Equal(t, SinkTypeHTTP, c.Aggregations[1].Sinks[2].Type)
+		assert.Equal(t, 5*time.Second, c.Aggregations[1].Sinks[2].Timeout.Duration)
+	})
+
+	t.Run("unknown field", func(t *testing.T) {
+		_, err := Load(writeConfigFile(t, `aggregations:
+- name: a
+  configMapName: b
+  unknown: c
+`))
+		assert.Error(t, err)
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := Load(filepath.Join(t.TempDir(), "missing.yaml"))
+		assert.Error(t, err)
+	})
+}
+
+func Test_Validate(t *testing.T) {
+	assert.Error(t, (&Config{}).Validate())
+	assert.Error(t, (&Config{Aggregations: []Aggregation{{ConfigMapName: "a"}}}).Validate())
+	assert.Error(t, (&Config{Aggregations: []Aggregation{{Name: "a"}}}).Validate())
+	assert.Error(t, (&Config{Aggregations: []Aggregation{{Name: "a", ConfigMapName: "a"}, {Name: "a", ConfigMapName: "b"}}}).Validate())
+	assert.Error(t, (&Config{Aggregations: []Aggregation{{Name: "a", ConfigMapName: "a"}, {Name: "b", ConfigMapName: "a"}}}).Validate())
+	// The shards and the revisions of a ConfigMap can't be shared even with different keys.
+	assert.Error(t, (&Config{Aggregations: []Aggregation{{Name: "a", ConfigMapName: "a"}, {Name: "b", ConfigMapName: "a", ConfigMapKey: "b.yaml"}}}).Validate())
+	assert.NoError(t, (&Config{Aggregations: []Aggregation{{Name: "a", ConfigMapName: "a"}, {Name: "b", ConfigMapName: "a", ConfigMapNamespace: "ns1"}}}).Validate())
+	assert.Error(t, (&Config{Aggregations: []Aggregation{{Name: "a", ConfigMapName: "a"}, {Name: "b", ConfigMapName: "b", Sinks: []Sink{{Type: SinkTypeConfigMap, Name: "a"}}}}}).Validate())
+	assert.Error(t, (&Config{Aggregations: []Aggregation{{Name: "a", ConfigMapName: "a", Sinks: []Sink{{Type: SinkTypeConfigMap, Name: "a"}}}}}).Validate())
+	assert.Error(t, (&Config{Aggregations: []Aggregation{{Name: "a", ConfigMapName: "a", NamespaceSelector: "a in ("}}}).Validate())
+	assert.Error(t, (&Config{Aggregations: []Aggregation{{Name: "a", ConfigMapName: "a", ExcludeNamespaces: "("}}}).Validate())
+	assert.NoError(t, (&Config{Aggregations: []Aggregation{{Name: "a", ConfigMapName: "a", NamespaceSelector: "env=prod", ExcludeNamespaces: "^kube-"}}}).Validate())
+	assert.Error(t, (&Config{Aggregations: []Aggregation{{Name: "a", ConfigMapName: "a", Namespaced: true}}}).Validate())
+	assert.Error(t, (&Config{Aggregations: []Aggregation{{Name: "a", ConfigMapName: "a", Namespaced: true, IncludeNamespaces: []string{"ns1"}, NamespaceSelector: "env=prod"}}}).Validate())
+	assert.NoError(t, (&Config{Aggregations: []Aggregation{{Name: "a", ConfigMapName: "a", Namespaced: true, IncludeNamespaces: []string{"ns1"}}}}).Validate())
+	limit := -1
+	assert.Error(t, (&Config{Aggregations: []Aggregation{{Name: "a", ConfigMapName: "a", RevisionHistoryLimit: &limit}}}).Validate())
+	assert.Error(t, (&Config{Aggregations: []Aggregation{{Name: "a", ConfigMapName: "a", StaleConfigGracePeriod: &metav1.Duration{Duration: -time.Hour}}}}).Validate())
+	percent, configs := 101, -1
+	assert.Error(t, (&Config{Aggregations: []Aggregation{{Name: "a", ConfigMapName: "a", MaxRemovedPercent: &percent}}}).Validate())
+	assert.Error(t, (&Config{Aggregations: []Aggregation{{Name: "a", ConfigMapName: "a", MaxRemovedConfigs: &configs}}}).Validate())
+	assert.Error(t, (&Config{Aggregations: []Aggregation{{Name: "a", ConfigMapName: "a", SourceDirs: []string{""}}}}).Validate())
+	assert.NoError(t, (&Config{Aggregations: []Aggregation{{Name: "a", ConfigMapName: "a", SourceDirs: []string{"/configs"}}}}).Validate())
+}
+
+func Test_ValidateConfigMaps(t *testing.T) {
+	c := &Config{Aggregations: []Aggregation{{Name: "a", ConfigMapName: "a"}, {Name: "b", ConfigMapName: "a", ConfigMapNamespace: "ns1"}}}
+	assert.NoError(t, c.Validate())
+	assert.NoError(t, c.ValidateConfigMaps("ns2"))
+	// The default namespace is the namespace of the aggregator.
+	assert.Error(t, c.ValidateConfigMaps("ns1"))
+	c = &Config{Aggregations: []Aggregation{{Name: "a", ConfigMapName: "a"}, {Name: "b", ConfigMapName: "b", Sinks: []Sink{{Type: SinkTypeConfigMap, Namespace: "ns1", Name: "a"}}}}}
+	assert.NoError(t, c.Validate())
+	assert.Error(t, c.ValidateConfigMaps("ns1"))
+}
+
+func Test_GitSource_Validate(t *testing.T) {
+	assert.NoError(t, GitSource{Repo: "/repo"}.Validate())
+	assert.NoError(t, GitSource{Repo: "/repo", Ref: "main", PathPattern: "apps/*/*.yaml"}.Validate())
+	assert.Error(t, GitSource{}.Validate())
+	assert.Error(t, GitSource{Repo: "/repo", Ref: "--output=/tmp/a"}.Validate())
+	assert.Error(t, GitSource{Repo: "/repo", PathPattern: "apps/[/*.yaml"}.Validate())
+}
+
+func Test_Sink_Validate(t *testing.T) {
+	assert.NoError(t, Sink{Type: SinkTypeConfigMap, Name: "a"}.Validate())
+	assert.Error(t, Sink{Type: SinkTypeSecret}.Validate())
+	assert.Error(t, Sink{Type: SinkTypeFile}.Validate())
+	assert.NoError(t, Sink{Type: SinkTypeHTTP, URL: "http://a/b"}.Validate())
+	assert.Error(t, Sink{Type: SinkTypeHTTP, URL: "a/b"}.Validate())
+	assert.NoError(t, Sink{Type: SinkTypeRedis, URL: "redis://localhost:6379/0"}.Validate())
+	assert.Error(t, Sink{Type: SinkTypeRedis, URL: "localhost:6379"}.Validate())
+	assert.Error(t, Sink{Type: "unknown"}.Validate())
+}
+
+func Test_ValidateLocal(t *testing.T) {
+	local := Aggregation{Name: "a", ConfigMapName: "a", SourceDirs: []string{"./configs"}, Sinks: []Sink{{Type: SinkTypeFile, Path: "./config.yaml"}}}
+	assert.NoError(t, (&Config{Aggregations: []Aggregation{local}}).ValidateLocal())
+	noSource := local
+	noSource.SourceDirs = nil
+	assert.Error(t, (&Config{Aggregations: []Aggregation{noSource}}).ValidateLocal())
+	selector := local
+	selector.NamespaceSelector = "env=prod"
+	assert.Error(t, (&Config{Aggregations: []Aggregation{selector}}).ValidateLocal())
+	namespaced := local
+	namespaced.Namespaced = true
+	assert.Error(t, (&Config{Aggregations: []Aggregation{namespaced}}).ValidateLocal())
+	secret := local
+	secret.Sinks = []Sink{{Type: SinkTypeSecret, Name: "s"}}
+	assert.Error(t, (&Config{Aggregations: []Aggregation{secret}}).ValidateLocal())
+}
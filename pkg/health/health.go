@@ -0,0 +1,28 @@
+package health
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Check returns an error if the check fails.
+type Check func() error
+
+// Handler returns the HTTP handler responding 200 if all the checks pass, otherwise 503 with the errors.
+func Handler(checks ...Check) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var errs []string
+		for _, check := range checks {
+			if err := check(); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if len(errs) > 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(strings.Join(errs, "\n") + "\n"))
+			return
+		}
+		_, _ = w.Write([]byte("ok\n"))
+	})
+}
@@ -8,6 +8,8 @@ import (
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/leaderelection"
 	"k8s.io/client-go/tools/leaderelection/resourcelock"
+
+	"github.com/numaproj-labs/numalogic-config-aggregator/pkg/metrics"
 )
 
 // A k8s based leader election implementation
@@ -59,8 +61,14 @@ func (ke *k8selector) RunOrDie(ctx context.Context, callbacks LeaderCallbacks) {
 		RenewDeadline:   ke.renewDeadline,
 		RetryPeriod:     ke.retryPeriod,
 		Callbacks: leaderelection.LeaderCallbacks{
-			OnStartedLeading: callbacks.OnStartedLeading,
-			OnStoppedLeading: callbacks.OnStoppedLeading,
+			OnStartedLeading: func(ctx context.Context) {
+				metrics.Leader.Set(1)
+				callbacks.OnStartedLeading(ctx)
+			},
+			OnStoppedLeading: func() {
+				metrics.Leader.Set(0)
+				callbacks.OnStoppedLeading()
+			},
 		},
 	})
 }
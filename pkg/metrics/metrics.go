@@ -0,0 +1,125 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	namespace = "numalogic_config_aggregator"
+
+	LabelAggregation = "aggregation"
+	LabelNamespace   = "namespace"
+	LabelStatus      = "status"
+	LabelSink        = "sink"
+	LabelChange      = "change"
+)
+
+var (
+	// RunsTotal is the total number of aggregation runs.
+	RunsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "runs_total",
+		Help:      "Total number of aggregation runs.",
+	}, []string{LabelAggregation})
+
+	// RunsFailedTotal is the total number of failed aggregation runs.
+	RunsFailedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "runs_failed_total",
+		Help:      "Total number of failed aggregation runs.",
+	}, []string{LabelAggregation})
+
+	// RunDuration is the duration of the aggregation runs.
+	RunDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "run_duration_seconds",
+		Help:      "Duration of the aggregation runs in seconds.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 2, 12),
+	}, []string{LabelAggregation})
+
+	// SourcesDiscovered is the number of application config sources discovered in the last run.
+	SourcesDiscovered = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "sources_discovered",
+		Help:      "Number of application config sources discovered in the last run.",
+	}, []string{LabelAggregation})
+
+	// AppConfigs is the number of application config sources per namespace and status in the last run.
+	AppConfigs = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "app_configs",
+		Help:      "Number of application config sources per namespace and status (Valid, Invalid or Empty) in the last run.",
+	}, []string{LabelAggregation, LabelNamespace, LabelStatus})
+
+	// PayloadSize is the size of the aggregated config.
+	PayloadSize = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "aggregated_payload_bytes",
+		Help:      "Size of the aggregated config in bytes.",
+	}, []string{LabelAggregation})
+
+	// MaxPayloadSize is the max size of the aggregated config in one ConfigMap before it's sharded.
+	MaxPayloadSize = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "aggregated_payload_max_bytes",
+		Help:      "Max size of the aggregated config in one ConfigMap before it's sharded.",
+	}, []string{LabelAggregation})
+
+	// Shards is the number of the shards of the aggregated ConfigMap.
+	Shards = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "aggregated_configmap_shards",
+		Help:      "Number of the shards of the aggregated ConfigMap, 0 if it's not sharded.",
+	}, []string{LabelAggregation})
+
+	// LastSuccessfulUpdate is the timestamp of the last successful run, when the aggregated config is up to date.
+	LastSuccessfulUpdate = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "last_successful_update_timestamp_seconds",
+		Help:      "Unix timestamp of the last successful run, when the aggregated config is up to date.",
+	}, []string{LabelAggregation})
+
+	// SinkWritesFailedTotal is the number of failed writes to each sink.
+	SinkWritesFailedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "sink_writes_failed_total",
+		Help:      "Total number of failed writes of the aggregated config to each sink.",
+	}, []string{LabelAggregation, LabelSink})
+
+	// StaleConfigs is the number of the application configs kept from their last valid versions.
+	StaleConfigs = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "stale_configs",
+		Help:      "Number of the application configs kept from their last valid versions in the aggregated config.",
+	}, []string{LabelAggregation})
+
+	// PublishBlocked is 1 if the aggregated config is not published since too many application configs would be removed.
+	PublishBlocked = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "publish_blocked",
+		Help:      "Whether the aggregated config is not published (1) since too many application configs would be removed, or not (0).",
+	}, []string{LabelAggregation})
+
+	// DryRunChanges is the number of the services changed by the last dry run.
+	DryRunChanges = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "dry_run_changes",
+		Help:      "Number of the services added, removed or changed in the aggregated config by the last dry run.",
+	}, []string{LabelAggregation, LabelChange})
+
+	// Leader is 1 if the process is the leader, otherwise 0.
+	Leader = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "leader",
+		Help:      "Whether the process is the leader (1) or not (0).",
+	})
+)
+
+// Handler returns the HTTP handler serving the metrics.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,133 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Mode decides what to do with an invalid application ConfigMap.
+type Mode string
+
+const (
+	// ModeDeny rejects the invalid application ConfigMaps.
+	ModeDeny Mode = "deny"
+	// ModeWarn admits the invalid application ConfigMaps with warnings.
+	ModeWarn Mode = "warn"
+)
+
+// Validator validates the application ConfigMaps, it's implemented by the aggregators.
+type Validator interface {
+	ValidateConfigMap(context.Context, *corev1.ConfigMap) (bool, []string)
+}
+
+// Server is the validating admission webhook of the application ConfigMaps.
+type Server struct {
+	mode       Mode
+	validators []Validator
+	logger     *zap.SugaredLogger
+}
+
+// NewServer returns a webhook server validating the application ConfigMaps with all the validators.
+func NewServer(mode Mode, logger *zap.SugaredLogger, validators ...Validator) (*Server, error) {
+	if mode != ModeDeny && mode != ModeWarn {
+		return nil, fmt.Errorf("unknown webhook mode %q, it should be either %q or %q", mode, ModeDeny, ModeWarn)
+	}
+	return &Server{mode: mode, validators: validators, logger: logger}, nil
+}
+
+// Start serves the webhook with the tls.crt and tls.key in the certDir, until the context is done.
+func (s *Server) Start(ctx context.Context, addr, certDir string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/validate", s)
+	server := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		_ = server.Shutdown(context.Background())
+	}()
+	err := server.ListenAndServeTLS(filepath.Join(certDir, "tls.crt"), filepath.Join(certDir, "tls.key"))
+	if err != nil && err != http.ErrServerClosed {
+		return fmt.Errorf("failed to serve the webhook, %w", err)
+	}
+	return nil
+}
+
+// ServeHTTP handles the AdmissionReview requests.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to read the request, %v", err), http.StatusBadRequest)
+		return
+	}
+	review := &admissionv1.AdmissionReview{}
+	if err := json.Unmarshal(body, review); err != nil || review.Request == nil {
+		http.Error(w, "invalid admission review request", http.StatusBadRequest)
+		return
+	}
+	review.Response = s.review(r.Context(), review.Request)
+	review.Response.UID = review.Request.UID
+	review.Request = nil
+	respBytes, err := json.Marshal(review)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to marshal the response, %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(respBytes)
+}
+
+func (s *Server) review(ctx context.Context, req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	if req.Kind.Kind != "ConfigMap" || (req.Operation != admissionv1.Create && req.Operation != admissionv1.Update) {
+		return &admissionv1.AdmissionResponse{Allowed: true}
+	}
+	cm := &corev1.ConfigMap{}
+	if err := json.Unmarshal(req.Object.Raw, cm); err != nil {
+		return &admissionv1.AdmissionResponse{
+			Allowed: false,
+			Result:  &metav1.Status{Code: http.StatusBadRequest, Message: fmt.Sprintf("failed to decode the ConfigMap, %v", err)},
+		}
+	}
+	if cm.Namespace == "" {
+		cm.Namespace = req.Namespace
+	}
+	if req.Operation == admissionv1.Update && len(req.OldObject.Raw) > 0 {
+		oldCm := &corev1.ConfigMap{}
+		// An update not changing the configs, e.g. the status annotations of the aggregator, is allowed,
+		// even if the ConfigMap is already invalid.
+		if err := json.Unmarshal(req.OldObject.Raw, oldCm); err == nil && reflect.DeepEqual(oldCm.Data, cm.Data) && reflect.DeepEqual(oldCm.Labels, cm.Labels) {
+			return &admissionv1.AdmissionResponse{Allowed: true}
+		}
+	}
+	var errs []string
+	for _, v := range s.validators {
+		if matched, e := v.ValidateConfigMap(ctx, cm); matched {
+			errs = append(errs, e...)
+		}
+	}
+	if len(errs) == 0 {
+		return &admissionv1.AdmissionResponse{Allowed: true}
+	}
+	s.logger.Warnw("Invalid application ConfigMap", zap.String("namespace", cm.Namespace), zap.String("configmap", cm.Name), zap.Strings("errors", errs), zap.String("mode", string(s.mode)))
+	if s.mode == ModeWarn {
+		return &admissionv1.AdmissionResponse{Allowed: true, Warnings: errs}
+	}
+	return &admissionv1.AdmissionResponse{
+		Allowed: false,
+		Result: &metav1.Status{
+			Code:    http.StatusUnprocessableEntity,
+			Reason:  metav1.StatusReasonInvalid,
+			Message: fmt.Sprintf("invalid application config, %s", strings.Join(errs, "; ")),
+		},
+	}
+}
@@ -0,0 +1,99 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/numaproj-labs/numalogic-config-aggregator/pkg/logging"
+)
+
+type fakeValidator struct {
+	label string
+}
+
+func (v fakeValidator) ValidateConfigMap(_ context.Context, cm *corev1.ConfigMap) (bool, []string) {
+	if _, ok := cm.Labels[v.label]; !ok {
+		return false, nil
+	}
+	if cm.Data["hello"] != "valid" {
+		return true, []string{"hello: invalid"}
+	}
+	return true, nil
+}
+
+func doReview(t *testing.T, s *Server, op admissionv1.Operation, cm *corev1.ConfigMap, old ...*corev1.ConfigMap) *admissionv1.AdmissionResponse {
+	t.Helper()
+	raw, err := json.Marshal(cm)
+	assert.NoError(t, err)
+	var oldRaw []byte
+	if len(old) > 0 {
+		oldRaw, err = json.Marshal(old[0])
+		assert.NoError(t, err)
+	}
+	review := admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+		Request: &admissionv1.AdmissionRequest{
+			UID:       types.UID("uid"),
+			Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "ConfigMap"},
+			Namespace: "ns1",
+			Operation: op,
+			Object:    runtime.RawExtension{Raw: raw},
+			OldObject: runtime.RawExtension{Raw: oldRaw},
+		},
+	}
+	body, err := json.Marshal(review)
+	assert.NoError(t, err)
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader(body)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	result := admissionv1.AdmissionReview{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+	assert.Equal(t, types.UID("uid"), result.Response.UID)
+	return result.Response
+}
+
+func Test_Server(t *testing.T) {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "cm", Labels: map[string]string{"app": "a"}},
+		Data:       map[string]string{"hello": "valid"},
+	}
+	invalid := cm.DeepCopy()
+	invalid.Data["hello"] = "oops"
+	unlabeled := invalid.DeepCopy()
+	unlabeled.Labels = nil
+
+	s, err := NewServer(ModeDeny, logging.NewLogger(), fakeValidator{label: "app"})
+	assert.NoError(t, err)
+	assert.True(t, doReview(t, s, admissionv1.Create, cm).Allowed)
+	assert.True(t, doReview(t, s, admissionv1.Create, unlabeled).Allowed)
+	assert.True(t, doReview(t, s, admissionv1.Delete, invalid).Allowed)
+	resp := doReview(t, s, admissionv1.Update, invalid)
+	assert.False(t, resp.Allowed)
+	assert.Contains(t, resp.Result.Message, "hello: invalid")
+	// Only the changes of the configs are validated.
+	annotated := invalid.DeepCopy()
+	annotated.Annotations = map[string]string{"numalogic.numaproj.io/status": "Invalid"}
+	assert.True(t, doReview(t, s, admissionv1.Update, annotated, invalid).Allowed)
+	assert.False(t, doReview(t, s, admissionv1.Update, invalid, cm).Allowed)
+	assert.False(t, doReview(t, s, admissionv1.Update, invalid, unlabeled).Allowed)
+
+	s, err = NewServer(ModeWarn, logging.NewLogger(), fakeValidator{label: "app"})
+	assert.NoError(t, err)
+	resp = doReview(t, s, admissionv1.Create, invalid)
+	assert.True(t, resp.Allowed)
+	assert.Equal(t, []string{"hello: invalid"}, resp.Warnings)
+
+	_, err = NewServer("oops", logging.NewLogger())
+	assert.Error(t, err)
+}